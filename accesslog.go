@@ -0,0 +1,222 @@
+package goku
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "math/rand"
+    "net"
+    "strconv"
+    "strings"
+    "sync"
+    "text/template"
+    "time"
+)
+
+const (
+    // handle type, status, method, uri, duration, size and request id, e.g.
+    //      D 200 GET /home/index 1.200ms 1024 9f86d081884c7d65-1
+    ACCESS_LOG_DEFAULT = "default"
+    // Apache Common Log Format
+    ACCESS_LOG_COMMON = "common"
+    // Apache Combined Log Format
+    ACCESS_LOG_COMBINED = "combined"
+    // a json object per line
+    ACCESS_LOG_JSON = "json"
+)
+
+// the info about a request for the access log
+type AccessLogEntry struct {
+    Time       time.Time // the time the request started
+    Duration   time.Duration
+    RequestId  string
+    RemoteAddr string
+    User       string
+    Method     string
+    URI        string
+    Proto      string
+    Status     int
+    Size       int // bytes of response body
+    Referer    string
+    UserAgent  string
+    RouteName  string
+    Controller string
+    Action     string
+    IsStatic   bool
+}
+
+// HandleType gets the type of handle:
+// "S" for static file, "D" for dynamic request, "N" for not matched route
+func (e *AccessLogEntry) HandleType() string {
+    if e.RouteName == "" {
+        return "N"
+    }
+    if e.IsStatic {
+        return "S"
+    }
+    return "D"
+}
+
+// Ms gets the duration in milliseconds
+func (e *AccessLogEntry) Ms() float64 {
+    return float64(e.Duration) / float64(time.Millisecond)
+}
+
+func createAccessLogEntry(ctx *HttpContext) *AccessLogEntry {
+    r := ctx.Request
+    e := &AccessLogEntry{
+        Time:       ctx.startTime,
+        Duration:   time.Since(ctx.startTime),
+        RequestId:  ctx.requestId,
+        RemoteAddr: r.RemoteAddr,
+        User:       ctx.User,
+        Method:     r.Method,
+        URI:        r.RequestURI,
+        Proto:      r.Proto,
+        Status:     ctx.responseStatusCode,
+        Size:       ctx.responseSize,
+        Referer:    r.Referer(),
+        UserAgent:  r.UserAgent(),
+    }
+    if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+        e.RemoteAddr = host
+    }
+    if e.Status == 0 {
+        e.Status = 200
+    }
+    if ctx.RouteData != nil {
+        e.RouteName = ctx.RouteData.Route.Name
+        e.IsStatic = ctx.RouteData.Route.IsStatic
+        if !e.IsStatic {
+            e.Controller = ctx.RouteData.Controller
+            e.Action = ctx.RouteData.Action
+        }
+    }
+    return e
+}
+
+// AccessLogger writes a line for every request.
+//      // write combined log to a file, rotate by 100MB
+//      w, err := goku.CreateRotateFileWriter("/var/log/myapp/access.log", 100<<20)
+//      al := goku.CreateAccessLogger(goku.ACCESS_LOG_COMBINED, w)
+//      al.ExcludeStatic = true
+//      config.AccessLogger = al
+type AccessLogger struct {
+    // "default", "common", "combined", "json",
+    // or a text/template with the fields of AccessLogEntry, e.g.
+    //      {{.RemoteAddr}} "{{.Method}} {{.URI}}" {{.Status}} {{.Ms}}ms
+    Format string
+    // where the log write to,
+    // if nil, write to Logger() in LOG_LEVEL_LOG
+    Out io.Writer
+    // the rate of the requests to be logged, between 0 and 1,
+    // 0 means log all the requests.
+    // the responses with status code >= 400 are always logged
+    SampleRate float64
+    // do not log the static file requests
+    ExcludeStatic bool
+
+    tmpl *template.Template
+    mu   sync.Mutex
+}
+
+// CreateAccessLogger creates an access logger,
+// panic if the format is a wrong template
+func CreateAccessLogger(format string, out io.Writer) *AccessLogger {
+    al := &AccessLogger{
+        Format: format,
+        Out:    out,
+    }
+    switch format {
+    case "", ACCESS_LOG_DEFAULT, ACCESS_LOG_COMMON, ACCESS_LOG_COMBINED, ACCESS_LOG_JSON:
+    default:
+        al.tmpl = template.Must(template.New("accesslog").Parse(format))
+    }
+    return al
+}
+
+var defaultAccessLogger = CreateAccessLogger(ACCESS_LOG_DEFAULT, nil)
+
+// Log writes the access log of the request
+func (al *AccessLogger) Log(ctx *HttpContext) {
+    if al.Out == nil && Logger().LogLevel() < LOG_LEVEL_LOG {
+        return
+    }
+    e := createAccessLogEntry(ctx)
+    if al.ExcludeStatic && e.IsStatic {
+        return
+    }
+    if al.SampleRate > 0 && al.SampleRate < 1 && e.Status < 400 && rand.Float64() >= al.SampleRate {
+        return
+    }
+    line := al.FormatEntry(e)
+    if al.Out == nil {
+        Logger().Log(line)
+        return
+    }
+    al.mu.Lock()
+    io.WriteString(al.Out, line)
+    al.mu.Unlock()
+}
+
+// FormatEntry formats the entry to a line, end with "\n"
+func (al *AccessLogger) FormatEntry(e *AccessLogEntry) string {
+    var b bytes.Buffer
+    switch al.Format {
+    case "", ACCESS_LOG_DEFAULT:
+        fmt.Fprintf(&b, "%s %d %s %s %.3fms %d %s", e.HandleType(), e.Status, e.Method, e.URI, e.Ms(), e.Size, e.RequestId)
+    case ACCESS_LOG_COMMON, ACCESS_LOG_COMBINED:
+        user := e.User
+        if user == "" {
+            user = "-"
+        }
+        size := "-"
+        if e.Size > 0 {
+            size = strconv.Itoa(e.Size)
+        }
+        fmt.Fprintf(&b, "%s - %s [%s] \"%s %s %s\" %d %s",
+            e.RemoteAddr, user, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+            e.Method, e.URI, e.Proto, e.Status, size)
+        if al.Format == ACCESS_LOG_COMBINED {
+            fmt.Fprintf(&b, " %s %s", strconv.Quote(e.Referer), strconv.Quote(e.UserAgent))
+        }
+    case ACCESS_LOG_JSON:
+        kv := []interface{}{
+            "time", e.Time.Format(time.RFC3339Nano),
+            "request_id", e.RequestId,
+            "remote_addr", e.RemoteAddr,
+            "user", e.User,
+            "method", e.Method,
+            "uri", e.URI,
+            "proto", e.Proto,
+            "status", e.Status,
+            "size", e.Size,
+            "duration_ms", e.Ms(),
+            "referer", e.Referer,
+            "user_agent", e.UserAgent,
+            "route", e.RouteName,
+            "controller", e.Controller,
+            "action", e.Action,
+        }
+        b.WriteByte('{')
+        eachField(kv, func(key string, val interface{}) {
+            if b.Len() > 1 {
+                b.WriteByte(',')
+            }
+            writeJsonValue(&b, key)
+            b.WriteByte(':')
+            writeJsonValue(&b, val)
+        })
+        b.WriteByte('}')
+    default:
+        if err := al.tmpl.Execute(&b, e); err != nil {
+            b.Reset()
+            b.WriteString("AccessLogger: template error, " + err.Error())
+        }
+    }
+    line := b.String()
+    if !strings.HasSuffix(line, "\n") {
+        line += "\n"
+    }
+    return line
+}
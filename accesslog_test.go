@@ -0,0 +1,56 @@
+package goku
+
+import (
+    "bytes"
+    "encoding/json"
+    "net/http/httptest"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+    ctx, _ := createTestContext("GET", "/blog/show?id=3", map[string]string{
+        "Referer":    "http://example.com/",
+        "User-Agent": "goku-test",
+    })
+    ctx.Request.RemoteAddr = "10.0.0.1:5678"
+    ctx.Request.RequestURI = "/blog/show?id=3"
+    ctx.RouteData = &RouteData{Route: &Route{Name: "default"}, Controller: "blog", Action: "show"}
+    ctx.WriteString("hello")
+    ctx.flushToResponse()
+
+    e := createAccessLogEntry(ctx)
+    assert.Equals(t, e.Size, 5)
+    assert.Equals(t, e.RemoteAddr, "10.0.0.1")
+
+    al := CreateAccessLogger(ACCESS_LOG_COMBINED, nil)
+    line := al.FormatEntry(e)
+    assert.StringContains(t, line, "10.0.0.1 - - [")
+    assert.StringContains(t, line, "] \"GET /blog/show?id=3 HTTP/1.1\" 200 5 \"http://example.com/\" \"goku-test\"\n")
+
+    al = CreateAccessLogger(ACCESS_LOG_JSON, nil)
+    var m map[string]interface{}
+    err := json.Unmarshal([]byte(al.FormatEntry(e)), &m)
+    assert.Equals(t, err, nil)
+    assert.Equals(t, m["controller"], "blog")
+    assert.Equals(t, m["status"], float64(200))
+
+    al = CreateAccessLogger("{{.HandleType}} {{.RouteName}} {{.Controller}}.{{.Action}}", nil)
+    assert.Equals(t, al.FormatEntry(e), "D default blog.show\n")
+}
+
+func TestAccessLogger(t *testing.T) {
+    out := new(bytes.Buffer)
+    al := CreateAccessLogger("{{.URI}}", out)
+    al.ExcludeStatic = true
+    rh := createTestHandler(&ServerConfig{})
+    rh.RouteTable.Static("static", "/static/(.*)")
+    rh.RouteTable.Routes = append(rh.RouteTable.Routes[1:], rh.RouteTable.Routes[0])
+    rh.AccessLogger = al
+
+    for _, url := range []string{"/static/a.css", "/nocontroller/index"} {
+        req := httptest.NewRequest("GET", url, nil)
+        rh.ServeHTTP(httptest.NewRecorder(), req)
+    }
+    assert.Equals(t, out.String(), "/nocontroller/index\n")
+}
@@ -4,6 +4,7 @@ import (
     "bytes"
     "io"
     "net/http"
+    "time"
     //"fmt"
 )
 
@@ -58,7 +59,9 @@ type ViewResult struct {
     IsPartial      bool // if is Partial, not use layout
 }
 
-func (vr *ViewResult) Render(ctx *HttpContext, wr io.Writer) {
+// Render renders the view to wr, nothing is written if returns an error,
+// e.g. the view not found, or a *TemplateError
+func (vr *ViewResult) Render(ctx *HttpContext, wr io.Writer) error {
     if vr.ViewEngine == nil {
         vr.ViewEngine = ctx.requestHandler.ViewEnginer
     }
@@ -66,27 +69,58 @@ func (vr *ViewResult) Render(ctx *HttpContext, wr io.Writer) {
         vr.TemplateEngine = ctx.requestHandler.TemplateEnginer
     }
     vi := &ViewInfo{
-        Controller: ctx.RouteData.Controller,
-        Action:     ctx.RouteData.Action,
-        View:       vr.ViewName,
-        Layout:     vr.Layout,
-        IsPartial:  vr.IsPartial,
+        View:      vr.ViewName,
+        Layout:    vr.Layout,
+        IsPartial: vr.IsPartial,
+    }
+    // no RouteData if no route matched, e.g. the 404 error view
+    if ctx.RouteData != nil {
+        vi.Controller, vi.Action = ctx.RouteData.Controller, ctx.RouteData.Action
     }
     viewData := &ViewData{
-        Data:    vr.ViewData,
-        Model:   vr.ViewModel,
-        Globals: globalViewData,
+        Data:     vr.ViewData,
+        Model:    vr.ViewModel,
+        Globals:  getGlobalViewData(),
+        renderer: &viewRenderer{
+            viewEngine:     vr.ViewEngine,
+            templateEngine: vr.TemplateEngine,
+            controller:     vi.Controller,
+        },
+    }
+    viewFile, layoutFiles, err := findViewFiles(vr.ViewEngine, vi)
+    if err != nil {
+        return err
     }
-    viewFile, layoutFile := vr.ViewEngine.FindView(vi)
-    vr.TemplateEngine.Render(viewFile, layoutFile, viewData, wr)
+    defer recordTemplateRender(ctx, viewFile, time.Now())
+    _, span := StartSpan(ctx.Context(), "template.render")
+    span.SetAttribute("goku.view", viewFile)
+    defer span.End()
+    err = renderViewFiles(vr.TemplateEngine, viewFile, layoutFiles, viewData, wr)
+    span.SetError(err)
+    return err
 }
 
+// the render error is handled like the action returns it,
+// a 500 response, or the dev error page in debug mode
 func (vr *ViewResult) ExecuteResult(ctx *HttpContext) {
-    vr.notShowDevError = true
-    vr.Render(ctx, vr.Body)
+    if err := vr.renderBody(ctx); err != nil {
+        ctx.Logger().Error("render view error", "error", err)
+        errorResultOf(err).ExecuteResult(ctx)
+        return
+    }
     vr.ActionResult.ExecuteResult(ctx)
 }
 
+// render the view to Body, the Body is empty if failed
+func (vr *ViewResult) renderBody(ctx *HttpContext) error {
+    vr.notShowDevError = true
+    if err := vr.Render(ctx, vr.Body); err != nil {
+        vr.Body.Reset()
+        return err
+    }
+    return nil
+}
+
 type ContentResult struct {
     FilePath string
 }
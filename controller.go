@@ -22,6 +22,12 @@ type ActionInfo struct {
     Controller *ControllerInfo
     Handler    func(ctx *HttpContext) ActionResulter
     Filters    []Filter
+
+    // set instead of Handler if the action returns an error too
+    HandlerWithError func(ctx *HttpContext) (ActionResulter, error)
+
+    // maximum size of request body, use the controller's if 0, no limit if -1
+    MaxBodyBytes int64
 }
 
 // AddFilters adds filters to the action
@@ -38,6 +44,9 @@ type ControllerInfo struct {
     Name    string
     Actions map[string]*ActionInfo
     Filters []Filter
+
+    // maximum size of request body, use the ServerConfig's if 0, no limit if -1
+    MaxBodyBytes int64
 }
 
 func (ci *ControllerInfo) Init() *ControllerInfo {
@@ -62,6 +71,21 @@ func (ci *ControllerInfo) GetAction(method string, name string) *ActionInfo {
 // register a action to the controller
 func (ci *ControllerInfo) RegAction(httpMethod string, actionName string,
     handler func(ctx *HttpContext) ActionResulter) *ActionInfo {
+    ai := ci.regAction(httpMethod, actionName)
+    ai.Handler = handler
+    return ai
+}
+
+// RegActionE registers a action that returns an error too,
+// the returned error is turned into the error response, see HTTPError
+func (ci *ControllerInfo) RegActionE(httpMethod string, actionName string,
+    handler func(ctx *HttpContext) (ActionResulter, error)) *ActionInfo {
+    ai := ci.regAction(httpMethod, actionName)
+    ai.HandlerWithError = handler
+    return ai
+}
+
+func (ci *ControllerInfo) regAction(httpMethod string, actionName string) *ActionInfo {
     httpMethod = strings.ToLower(httpMethod)
     if httpMethod == "all" {
         httpMethod = ""
@@ -76,7 +100,6 @@ func (ci *ControllerInfo) RegAction(httpMethod string, actionName string,
     ai := &ActionInfo{
         Name:       strings.ToLower(actionName),
         Controller: ci,
-        Handler:    handler,
     }
     ci.Actions[index] = ai
     return ai
@@ -164,6 +187,44 @@ func (cb *ControllerBuilder) Delete(httpMethod string, actionName string,
     return cb.Action("delete", actionName, handler)
 }
 
+// ActionE is the same as Action, but the action returns an error too,
+// the error is turned into the error response, see HTTPError
+// The return value is the ControllerBuilder, so calls can be chained
+func (cb *ControllerBuilder) ActionE(httpMethod string, actionName string,
+    handler func(ctx *HttpContext) (ActionResulter, error)) *ControllerBuilder {
+
+    cb.currentAction = cb.controller.RegActionE(httpMethod, actionName, handler)
+    return cb
+}
+
+// reg http "get" method action that returns an error too
+// The return value is the ControllerBuilder, so calls can be chained
+func (cb *ControllerBuilder) GetE(actionName string,
+    handler func(ctx *HttpContext) (ActionResulter, error)) *ControllerBuilder {
+    return cb.ActionE("get", actionName, handler)
+}
+
+// reg http "post" method action that returns an error too
+// The return value is the ControllerBuilder, so calls can be chained
+func (cb *ControllerBuilder) PostE(actionName string,
+    handler func(ctx *HttpContext) (ActionResulter, error)) *ControllerBuilder {
+    return cb.ActionE("post", actionName, handler)
+}
+
+// reg http "put" method action that returns an error too
+// The return value is the ControllerBuilder, so calls can be chained
+func (cb *ControllerBuilder) PutE(actionName string,
+    handler func(ctx *HttpContext) (ActionResulter, error)) *ControllerBuilder {
+    return cb.ActionE("put", actionName, handler)
+}
+
+// reg http "delete" method action that returns an error too
+// The return value is the ControllerBuilder, so calls can be chained
+func (cb *ControllerBuilder) DeleteE(actionName string,
+    handler func(ctx *HttpContext) (ActionResulter, error)) *ControllerBuilder {
+    return cb.ActionE("delete", actionName, handler)
+}
+
 // The return value is the ControllerBuilder, so calls can be chained
 func (cb *ControllerBuilder) Filters(filters ...Filter) *ControllerBuilder {
     if cb.currentAction != nil {
@@ -174,6 +235,19 @@ func (cb *ControllerBuilder) Filters(filters ...Filter) *ControllerBuilder {
     return cb
 }
 
+// MaxBodyBytes sets the maximum size of request body
+// for the current action, or for the controller if no action registered yet.
+// -1 means no limit.
+// The return value is the ControllerBuilder, so calls can be chained
+func (cb *ControllerBuilder) MaxBodyBytes(n int64) *ControllerBuilder {
+    if cb.currentAction != nil {
+        cb.currentAction.MaxBodyBytes = n
+    } else {
+        cb.controller.MaxBodyBytes = n
+    }
+    return cb
+}
+
 // Controller gets a controller builder that the controller named "name"
 // for reg actions and filters
 func Controller(name string) *ControllerBuilder {
@@ -0,0 +1,194 @@
+package goku
+
+import (
+    "net/http"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+var (
+    defaultCorsMethods = []string{"GET", "POST", "PUT", "DELETE", "HEAD"}
+)
+
+// CorsOptions is the config for the cors middleware.
+//      opt := &goku.CorsOptions{
+//          AllowOrigins:     []string{"https://example.com", "https://*.example.com"},
+//          AllowHeaders:     []string{"Content-Type", "X-Requested-With"},
+//          AllowCredentials: true,
+//          MaxAge:           10 * time.Minute,
+//      }
+type CorsOptions struct {
+    // origins allowed to access the resources.
+    // "*" allow all the origins,
+    // an origin can contain one wildcard, e.g. "https://*.example.com".
+    // if empty and AllowOriginFunc is nil, allow all the origins
+    AllowOrigins []string
+    // custom function to check the origin,
+    // if set, AllowOrigins will be ignored
+    AllowOriginFunc func(origin string) bool
+    // methods allowed for cross-origin requests,
+    // default is GET, POST, PUT, DELETE, HEAD
+    AllowMethods []string
+    // headers the client allowed to use in cross-origin requests,
+    // if empty, reflect the headers from Access-Control-Request-Headers
+    AllowHeaders []string
+    // headers that are safe to expose to the client
+    ExposeHeaders []string
+    // whether the request can include credentials like cookies
+    AllowCredentials bool
+    // how long the results of a preflight request can be cached,
+    // not send if 0
+    MaxAge time.Duration
+}
+
+// CorsMiddleware handles the Cross-Origin Resource Sharing requests.
+// the preflight requests are answered in OnBeginRequest,
+// before route matched.
+type CorsMiddleware struct {
+    BaseMiddleware
+
+    Options *CorsOptions
+
+    allowAll       bool
+    origins        map[string]bool
+    wildcards      []*regexp.Regexp
+    methods        string
+    headers        string
+    exposedHeaders string
+    maxAge         string
+}
+
+// CreateCorsMiddleware creates a cors middleware,
+// if opt is nil, all the origins are allowed
+func CreateCorsMiddleware(opt *CorsOptions) *CorsMiddleware {
+    if opt == nil {
+        opt = &CorsOptions{}
+    }
+    cm := &CorsMiddleware{
+        Options: opt,
+        origins: make(map[string]bool),
+    }
+    if opt.AllowOriginFunc == nil {
+        if len(opt.AllowOrigins) == 0 {
+            cm.allowAll = true
+        }
+        for _, o := range opt.AllowOrigins {
+            o = strings.ToLower(o)
+            if o == "*" {
+                cm.allowAll = true
+            } else if strings.Contains(o, "*") {
+                // https://*.example.com => ^https://.*\.example\.com$
+                reg := "^" + strings.Replace(regexp.QuoteMeta(o), "\\*", ".*", -1) + "$"
+                cm.wildcards = append(cm.wildcards, regexp.MustCompile(reg))
+            } else {
+                cm.origins[o] = true
+            }
+        }
+    }
+    methods := opt.AllowMethods
+    if len(methods) == 0 {
+        methods = defaultCorsMethods
+    }
+    cm.methods = strings.ToUpper(strings.Join(methods, ", "))
+    cm.headers = strings.Join(opt.AllowHeaders, ", ")
+    cm.exposedHeaders = strings.Join(opt.ExposeHeaders, ", ")
+    if opt.MaxAge > 0 {
+        cm.maxAge = strconv.Itoa(int(opt.MaxAge / time.Second))
+    }
+    return cm
+}
+
+// isOriginAllowed checks whether the origin is allowed
+func (cm *CorsMiddleware) isOriginAllowed(origin string) bool {
+    if cm.Options.AllowOriginFunc != nil {
+        return cm.Options.AllowOriginFunc(origin)
+    }
+    if cm.allowAll {
+        return true
+    }
+    origin = strings.ToLower(origin)
+    if cm.origins[origin] {
+        return true
+    }
+    for _, re := range cm.wildcards {
+        if re.MatchString(origin) {
+            return true
+        }
+    }
+    return false
+}
+
+// the response differs by the origin
+// unless all the origins get the "*"
+func (cm *CorsMiddleware) varyByOrigin() bool {
+    return !cm.allowAll || cm.Options.AllowCredentials || cm.Options.AllowOriginFunc != nil
+}
+
+func (cm *CorsMiddleware) setAllowOrigin(ctx *HttpContext, origin string) {
+    if cm.varyByOrigin() {
+        ctx.SetHeader("Access-Control-Allow-Origin", origin)
+    } else {
+        ctx.SetHeader("Access-Control-Allow-Origin", "*")
+    }
+    if cm.Options.AllowCredentials {
+        ctx.SetHeader("Access-Control-Allow-Credentials", "true")
+    }
+}
+
+func (cm *CorsMiddleware) OnBeginRequest(ctx *HttpContext) (ActionResulter, error) {
+    origin := ctx.GetHeader("Origin")
+    isPreflight := ctx.Method == "OPTIONS" && ctx.GetHeader("Access-Control-Request-Method") != ""
+    if isPreflight {
+        addVary(ctx.Header(), "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+        ar := &ActionResult{StatusCode: http.StatusNoContent}
+        if origin == "" || !cm.isOriginAllowed(origin) {
+            return ar, nil
+        }
+        reqMethod := strings.ToUpper(ctx.GetHeader("Access-Control-Request-Method"))
+        if !strings.Contains(", "+cm.methods+", ", ", "+reqMethod+", ") {
+            return ar, nil
+        }
+        cm.setAllowOrigin(ctx, origin)
+        ctx.SetHeader("Access-Control-Allow-Methods", cm.methods)
+        if cm.headers != "" {
+            ctx.SetHeader("Access-Control-Allow-Headers", cm.headers)
+        } else if reqHeaders := ctx.GetHeader("Access-Control-Request-Headers"); reqHeaders != "" {
+            ctx.SetHeader("Access-Control-Allow-Headers", reqHeaders)
+        }
+        if cm.maxAge != "" {
+            ctx.SetHeader("Access-Control-Max-Age", cm.maxAge)
+        }
+        return ar, nil
+    }
+
+    if cm.varyByOrigin() {
+        addVary(ctx.Header(), "Origin")
+    }
+    if origin == "" || !cm.isOriginAllowed(origin) {
+        return nil, nil
+    }
+    cm.setAllowOrigin(ctx, origin)
+    if cm.exposedHeaders != "" {
+        ctx.SetHeader("Access-Control-Expose-Headers", cm.exposedHeaders)
+    }
+    return nil, nil
+}
+
+// addVary adds the names to the Vary header
+// if they are not there yet
+func addVary(h http.Header, names ...string) {
+    exists := make(map[string]bool)
+    for _, v := range h["Vary"] {
+        for _, name := range strings.Split(v, ",") {
+            exists[strings.ToLower(strings.TrimSpace(name))] = true
+        }
+    }
+    for _, name := range names {
+        if !exists[strings.ToLower(name)] {
+            h.Add("Vary", name)
+            exists[strings.ToLower(name)] = true
+        }
+    }
+}
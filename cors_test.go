@@ -0,0 +1,85 @@
+package goku
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+// create a HttpContext for test
+func createTestContext(method, url string, headers map[string]string) (*HttpContext, *httptest.ResponseRecorder) {
+    req, _ := http.NewRequest(method, url, nil)
+    for k, v := range headers {
+        req.Header.Set(k, v)
+    }
+    w := httptest.NewRecorder()
+    rh := &RequestHandler{
+        ServerConfig: &ServerConfig{},
+    }
+    return rh.buildContext(w, req), w
+}
+
+func TestCorsPreflight(t *testing.T) {
+    cm := CreateCorsMiddleware(&CorsOptions{
+        AllowOrigins:     []string{"https://example.com", "https://*.goku.com"},
+        AllowCredentials: true,
+        MaxAge:           10 * time.Minute,
+    })
+    ctx, w := createTestContext("OPTIONS", "/api/user", map[string]string{
+        "Origin":                         "https://api.goku.com",
+        "Access-Control-Request-Method":  "PUT",
+        "Access-Control-Request-Headers": "X-Token",
+    })
+    ar, err := cm.OnBeginRequest(ctx)
+    assert.Equals(t, err, nil)
+    assert.Equals(t, ar.(*ActionResult).StatusCode, http.StatusNoContent)
+    assert.Equals(t, w.Header().Get("Access-Control-Allow-Origin"), "https://api.goku.com")
+    assert.Equals(t, w.Header().Get("Access-Control-Allow-Credentials"), "true")
+    assert.Equals(t, w.Header().Get("Access-Control-Allow-Headers"), "X-Token")
+    assert.Equals(t, w.Header().Get("Access-Control-Max-Age"), "600")
+    assert.Equals(t, len(w.Header()["Vary"]), 3)
+
+    // not allowed origin
+    ctx, w = createTestContext("OPTIONS", "/api/user", map[string]string{
+        "Origin":                        "https://evil.com",
+        "Access-Control-Request-Method": "PUT",
+    })
+    ar, _ = cm.OnBeginRequest(ctx)
+    assert.NotEquals(t, ar, nil)
+    assert.Equals(t, w.Header().Get("Access-Control-Allow-Origin"), "")
+
+    // not allowed method
+    ctx, w = createTestContext("OPTIONS", "/api/user", map[string]string{
+        "Origin":                        "https://example.com",
+        "Access-Control-Request-Method": "PATCH",
+    })
+    cm.OnBeginRequest(ctx)
+    assert.Equals(t, w.Header().Get("Access-Control-Allow-Origin"), "")
+}
+
+func TestCorsActualRequest(t *testing.T) {
+    cm := CreateCorsMiddleware(&CorsOptions{
+        ExposeHeaders: []string{"X-Total"},
+    })
+    ctx, w := createTestContext("GET", "/api/user", map[string]string{
+        "Origin": "https://example.com",
+    })
+    ar, _ := cm.OnBeginRequest(ctx)
+    assert.Equals(t, ar, nil)
+    assert.Equals(t, w.Header().Get("Access-Control-Allow-Origin"), "*")
+    assert.Equals(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Total")
+    assert.Equals(t, w.Header().Get("Vary"), "")
+
+    cm = CreateCorsMiddleware(&CorsOptions{
+        AllowOriginFunc: func(origin string) bool { return origin == "https://example.com" },
+    })
+    ctx, w = createTestContext("GET", "/api/user", map[string]string{
+        "Origin": "https://example.com",
+    })
+    ctx.AddHeader("Vary", "Accept-Encoding, origin")
+    cm.OnBeginRequest(ctx)
+    assert.Equals(t, w.Header().Get("Access-Control-Allow-Origin"), "https://example.com")
+    assert.Equals(t, len(w.Header()["Vary"]), 1)
+}
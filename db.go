@@ -1,12 +1,14 @@
 package goku
 
 import (
+    "context"
     "database/sql"
     "errors"
     "fmt"
     "github.com/QLeelulu/goku/utils"
     "reflect"
     "strings"
+    "time"
 )
 
 var zeroVal reflect.Value
@@ -24,35 +26,81 @@ type SqlQueryInfo struct {
     Order  string
 }
 
-// base db
+// base db.
+// all the methods have a Context variant, e.g. SelectContext,
+// pass ctx.Context() to it, the query will be canceled
+// if the request is canceled or timeout
 type DB struct {
-    sql.DB
+    // the connection pool returned by sql.Open
+    *sql.DB
     // if Debug set to true,
     // will print the sql
     Debug bool
 }
 
 func (db *DB) showDebugInfo(query string, args ...interface{}) {
+    db.showDebugInfoContext(nil, query, args...)
+}
+
+// show the sql with the request id in c
+func (db *DB) showDebugInfoContext(c context.Context, query string, args ...interface{}) {
     if db.Debug {
-        Logger().Logf("SQL: %v\nPARAMS: %v\n", query, args)
+        if requestId := RequestIdFromContext(c); requestId != "" {
+            Logger().Logf("request_id=%s SQL: %v\nPARAMS: %v\n", requestId, query, args)
+        } else {
+            Logger().Logf("SQL: %v\nPARAMS: %v\n", query, args)
+        }
     }
 }
 
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
     db.showDebugInfo(query, args)
+    defer recordDBQuery(query, time.Now())
     return db.DB.Exec(query, args...)
 }
 
 func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
     db.showDebugInfo(query, args)
+    defer recordDBQuery(query, time.Now())
     return db.DB.Query(query, args...)
 }
 
 func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
     db.showDebugInfo(query, args)
+    defer recordDBQuery(query, time.Now())
     return db.DB.QueryRow(query, args...)
 }
 
+func (db *DB) ExecContext(c context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+    db.showDebugInfoContext(c, query, args)
+    defer recordDBQuery(query, time.Now())
+    span := startSqlSpan(c, query)
+    defer func() { finishSpan(span, err) }()
+    return db.DB.ExecContext(c, query, args...)
+}
+
+func (db *DB) QueryContext(c context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+    db.showDebugInfoContext(c, query, args)
+    defer recordDBQuery(query, time.Now())
+    span := startSqlSpan(c, query)
+    defer func() { finishSpan(span, err) }()
+    return db.DB.QueryContext(c, query, args...)
+}
+
+func (db *DB) QueryRowContext(c context.Context, query string, args ...interface{}) *sql.Row {
+    db.showDebugInfoContext(c, query, args)
+    defer recordDBQuery(query, time.Now())
+    defer startSqlSpan(c, query).End()
+    return db.DB.QueryRowContext(c, query, args...)
+}
+
+// start a span for the sql if there is a span in c, e.g. in the action
+func startSqlSpan(c context.Context, query string) *Span {
+    _, span := startChildSpan(c, "db."+sqlOperation(query))
+    span.SetAttribute("db.statement", query)
+    return span
+}
+
 /*
 func (db *DB) Exec(query string, args ...interface{}) (Result, error)
     func (db *DB) Prepare(query string) (*Stmt, error)
@@ -101,6 +149,12 @@ func (db *DB) limitSql(limit int, offset int) string {
 //      }
 //      rows, err := db.Select("blog", qi)
 func (db *DB) Select(table string, qi SqlQueryInfo) (*sql.Rows, error) {
+    return db.SelectContext(context.Background(), table, qi)
+}
+
+// SelectContext is the same as Select,
+// the query will be canceled if c is canceled
+func (db *DB) SelectContext(c context.Context, table string, qi SqlQueryInfo) (*sql.Rows, error) {
     if qi.Fields == "" {
         qi.Fields = "*"
     }
@@ -113,15 +167,19 @@ func (db *DB) Select(table string, qi SqlQueryInfo) (*sql.Rows, error) {
         db.orderSql(qi.Order),
         db.limitSql(qi.Limit, qi.Offset),
     )
-    return db.Query(query, qi.Params...)
+    return db.QueryContext(c, query, qi.Params...)
 }
 
 func (db *DB) Count(table string, where string, whereParams ...interface{}) (count int64, err error) {
+    return db.CountContext(context.Background(), table, where, whereParams...)
+}
+
+func (db *DB) CountContext(c context.Context, table string, where string, whereParams ...interface{}) (count int64, err error) {
     if where != "" {
         where = " WHERE " + where
     }
     query := "SELECT COUNT(*) FROM " + table + where
-    row := db.QueryRow(query, whereParams...)
+    row := db.QueryRowContext(c, query, whereParams...)
     err = row.Scan(&count)
     return
 }
@@ -135,6 +193,10 @@ func (db *DB) Count(table string, where string, whereParams ...interface{}) (cou
 //      rerult, err := db.Insert("blog", data)
 //      id, err := result.LastInsertId()
 func (db *DB) Insert(table string, vals map[string]interface{}) (result sql.Result, err error) {
+    return db.InsertContext(context.Background(), table, vals)
+}
+
+func (db *DB) InsertContext(c context.Context, table string, vals map[string]interface{}) (result sql.Result, err error) {
     l := len(vals)
     if vals == nil || l < 1 {
         return
@@ -157,7 +219,7 @@ func (db *DB) Insert(table string, vals map[string]interface{}) (result sql.Resu
         strings.Join(fields, ", "),
         strings.Join(values, ", "))
 
-    result, err = db.Exec(query, params...)
+    result, err = db.ExecContext(c, query, params...)
     return
 }
 
@@ -169,9 +231,13 @@ func (db *DB) Insert(table string, vals map[string]interface{}) (result sql.Resu
 // mean that struct's field "HelloWorld" in database table's field is "hello_world"
 // table name mapping use the same rule as field
 func (db *DB) InsertStruct(i interface{}) (sql.Result, error) {
+    return db.InsertStructContext(context.Background(), i)
+}
+
+func (db *DB) InsertStructContext(c context.Context, i interface{}) (sql.Result, error) {
     m := utils.StructToSnakeKeyMap(i)
     table := utils.SnakeCasedName(utils.StructName(i))
-    r, err := db.Insert(table, m)
+    r, err := db.InsertContext(c, table, m)
 
     if err == nil {
         insertId, err2 := r.LastInsertId()
@@ -205,6 +271,10 @@ func (db *DB) InsertStruct(i interface{}) (sql.Result, error) {
 }
 
 func (db *DB) Update(table string, vals map[string]interface{}, where string, whereParams ...interface{}) (result sql.Result, err error) {
+    return db.UpdateContext(context.Background(), table, vals, where, whereParams...)
+}
+
+func (db *DB) UpdateContext(c context.Context, table string, vals map[string]interface{}, where string, whereParams ...interface{}) (result sql.Result, err error) {
     if where == "" {
         panic("Can not update rows without where")
     }
@@ -232,21 +302,25 @@ func (db *DB) Update(table string, vals map[string]interface{}, where string, wh
         strings.Join(fields, ", "),
         where)
 
-    result, err = db.Exec(query, params...)
+    result, err = db.ExecContext(c, query, params...)
     return
 }
 
 func (db *DB) Delete(table string, where string, params ...interface{}) (result sql.Result, err error) {
+    return db.DeleteContext(context.Background(), table, where, params...)
+}
+
+func (db *DB) DeleteContext(c context.Context, table string, where string, params ...interface{}) (result sql.Result, err error) {
     if where == "" {
         panic("Can not delete rows without where")
     }
     query := fmt.Sprintf("DELETE FROM %s WHERE %s;", table, where)
 
-    result, err = db.Exec(query, params...)
+    result, err = db.ExecContext(c, query, params...)
     return
 }
 
-func (db *DB) rawSelectByStruct(structType reflect.Type, qi SqlQueryInfo) (rows *sql.Rows, fields []string, err error) {
+func (db *DB) rawSelectByStruct(c context.Context, structType reflect.Type, qi SqlQueryInfo) (rows *sql.Rows, fields []string, err error) {
     // nums of struct's fields
     lf := structType.NumField()
     // type's fields
@@ -270,7 +344,7 @@ func (db *DB) rawSelectByStruct(structType reflect.Type, qi SqlQueryInfo) (rows
     // TODO: check the fileds has specified ?
     qi.Fields = strings.Join(columns, ", ")
     // run query from db
-    rows, err = db.Select(tableName, qi)
+    rows, err = db.SelectContext(c, tableName, qi)
     return
 }
 
@@ -279,6 +353,10 @@ func (db *DB) rawSelectByStruct(structType reflect.Type, qi SqlQueryInfo) (rows
 // mean that struct's field "HelloWorld" in database table's field is "hello_world"
 // table name mapping use the same rule as field
 func (db *DB) GetStruct(s interface{}, where string, params ...interface{}) error {
+    return db.GetStructContext(context.Background(), s, where, params...)
+}
+
+func (db *DB) GetStructContext(c context.Context, s interface{}, where string, params ...interface{}) error {
 
     structType := reflect.TypeOf(s)
     if structType.Kind() != reflect.Ptr {
@@ -296,7 +374,7 @@ func (db *DB) GetStruct(s interface{}, where string, params ...interface{}) erro
         Where:  where,
         Params: params,
     }
-    rows, fields, err := db.rawSelectByStruct(structType, qi)
+    rows, fields, err := db.rawSelectByStruct(c, structType, qi)
     if err != nil {
         return err
     }
@@ -322,6 +400,10 @@ func (db *DB) GetStruct(s interface{}, where string, params ...interface{}) erro
 //     var blogs []Blog
 //     err := db.GetStructs(&blogs, SqlQueryInfo{})
 func (db *DB) GetStructs(slicePtr interface{}, qi SqlQueryInfo) error {
+    return db.GetStructsContext(context.Background(), slicePtr, qi)
+}
+
+func (db *DB) GetStructsContext(c context.Context, slicePtr interface{}, qi SqlQueryInfo) error {
     ptr := reflect.ValueOf(slicePtr)
     if ptr.Kind() != reflect.Ptr {
         return errors.New("db.GetStructs: needs a pointer to a slice")
@@ -333,7 +415,7 @@ func (db *DB) GetStructs(slicePtr interface{}, qi SqlQueryInfo) error {
 
     structType := sliceValue.Type().Elem()
 
-    rows, fields, err := db.rawSelectByStruct(structType, qi)
+    rows, fields, err := db.rawSelectByStruct(c, structType, qi)
     if err != nil {
         return err
     }
@@ -400,7 +482,7 @@ func OpenMysql(driverName, dataSourceName string) (db *MysqlDB, err error) {
         return
     }
     db = &MysqlDB{}
-    db.DB.DB = *db2
+    db.DB.DB = db2
     return
 }
 
@@ -0,0 +1,303 @@
+package goku
+
+import (
+    "encoding/json"
+    "fmt"
+    "html/template"
+    "net"
+    "net/http"
+    "net/http/pprof"
+    "reflect"
+    "runtime"
+    "sort"
+    "strings"
+    "time"
+)
+
+var processStartTime = time.Now()
+
+// DebugHandler serves the debug endpoints under Prefix:
+//      {Prefix}/               index of the endpoints
+//      {Prefix}/pprof/         pprof index, and all the profiles, e.g. {Prefix}/pprof/heap
+//      {Prefix}/routes         the route table
+//      {Prefix}/controllers    the registered controllers, actions and filters
+//      {Prefix}/config         the effective ServerConfig
+//      {Prefix}/templates      the template & view cache status
+//      {Prefix}/runtime        the runtime stats
+// it is enabled by ServerConfig.DebugPath, or mount it to your own mux:
+//      dh := goku.CreateDebugHandler("/_debug", server.Handler.(*goku.RequestHandler))
+//      http.Handle("/_debug/", dh)
+type DebugHandler struct {
+    Prefix string
+    // who can visit the endpoints, only the loopback requests if nil.
+    // the request is checked by RemoteAddr, so set it if behind a proxy
+    Auth func(r *http.Request) bool
+
+    rh *RequestHandler
+}
+
+// CreateDebugHandler creates a DebugHandler,
+// rh is for the routes, config and templates, can be nil
+func CreateDebugHandler(prefix string, rh *RequestHandler) *DebugHandler {
+    return &DebugHandler{
+        Prefix: strings.TrimRight(prefix, "/"),
+        rh:     rh,
+    }
+}
+
+// Match returns whether the url path is a debug endpoint
+func (dh *DebugHandler) Match(urlPath string) bool {
+    return urlPath == dh.Prefix || strings.HasPrefix(urlPath, dh.Prefix+"/")
+}
+
+func (dh *DebugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    auth := dh.Auth
+    if auth == nil {
+        auth = isLoopbackRequest
+    }
+    // do not let others know the endpoints exist
+    if !auth(r) {
+        http.NotFound(w, r)
+        return
+    }
+    name := strings.TrimPrefix(r.URL.Path, dh.Prefix)
+    switch {
+    case name == "" || name == "/":
+        dh.index(w, r)
+    case strings.HasPrefix(name, "/pprof/"):
+        dh.pprof(w, r, strings.TrimPrefix(name, "/pprof/"))
+    case name == "/routes":
+        writeDebugJson(w, dh.routes())
+    case name == "/controllers":
+        writeDebugJson(w, debugControllers())
+    case name == "/config":
+        writeDebugJson(w, dh.config())
+    case name == "/templates":
+        writeDebugJson(w, dh.templates())
+    case name == "/runtime":
+        writeDebugJson(w, debugRuntime())
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+var debugIndexTmpl = template.Must(template.New("debug").Parse(`<html><head><title>goku debug</title></head><body>
+<h1>goku debug</h1>
+<ul>
+<li><a href="{{.}}/pprof/">pprof</a></li>
+<li><a href="{{.}}/routes">routes</a></li>
+<li><a href="{{.}}/controllers">controllers</a></li>
+<li><a href="{{.}}/config">config</a></li>
+<li><a href="{{.}}/templates">templates</a></li>
+<li><a href="{{.}}/runtime">runtime</a></li>
+</ul>
+</body></html>`))
+
+func (dh *DebugHandler) index(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    debugIndexTmpl.Execute(w, dh.Prefix)
+}
+
+func (dh *DebugHandler) pprof(w http.ResponseWriter, r *http.Request, name string) {
+    switch name {
+    case "":
+        // pprof.Index only works under /debug/pprof/,
+        // the links in the page are relative, so it is ok under the prefix
+        r2 := new(http.Request)
+        *r2 = *r
+        u := *r.URL
+        u.Path = "/debug/pprof/"
+        r2.URL = &u
+        pprof.Index(w, r2)
+    case "cmdline":
+        pprof.Cmdline(w, r)
+    case "profile":
+        pprof.Profile(w, r)
+    case "symbol":
+        pprof.Symbol(w, r)
+    case "trace":
+        pprof.Trace(w, r)
+    default:
+        pprof.Handler(name).ServeHTTP(w, r)
+    }
+}
+
+func (dh *DebugHandler) routes() interface{} {
+    routes := make([]map[string]interface{}, 0)
+    if dh.rh == nil || dh.rh.RouteTable == nil {
+        return routes
+    }
+    for _, r := range dh.rh.RouteTable.Routes {
+        routes = append(routes, map[string]interface{}{
+            "Name":       r.Name,
+            "Pattern":    r.Pattern,
+            "Default":    r.Default,
+            "Constraint": r.Constraint,
+            "IsStatic":   r.IsStatic,
+            "Timeout":    r.Timeout.String(),
+        })
+    }
+    return routes
+}
+
+func filterNames(filters []Filter) []string {
+    names := make([]string, 0, len(filters))
+    for _, f := range filters {
+        names = append(names, fmt.Sprintf("%T", f))
+    }
+    return names
+}
+
+func debugControllers() interface{} {
+    names := make([]string, 0, len(defaultControllerFactory.Controllers))
+    for name := range defaultControllerFactory.Controllers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    controllers := make([]map[string]interface{}, 0, len(names))
+    for _, name := range names {
+        ci := defaultControllerFactory.Controllers[name]
+        keys := make([]string, 0, len(ci.Actions))
+        for key := range ci.Actions {
+            keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        actions := make([]map[string]interface{}, 0, len(keys))
+        for _, key := range keys {
+            ai := ci.Actions[key]
+            // the key is {method}_{action}
+            method := key[:strings.Index(key, "_")]
+            actions = append(actions, map[string]interface{}{
+                "Name":         ai.Name,
+                "Method":       strings.ToUpper(method),
+                "Filters":      filterNames(ai.Filters),
+                "MaxBodyBytes": ai.MaxBodyBytes,
+            })
+        }
+        controllers = append(controllers, map[string]interface{}{
+            "Name":         ci.Name,
+            "Filters":      filterNames(ci.Filters),
+            "MaxBodyBytes": ci.MaxBodyBytes,
+            "Actions":      actions,
+        })
+    }
+    return controllers
+}
+
+// the exported fields of the ServerConfig,
+// the fields not a simple value are shown as the type name
+func (dh *DebugHandler) config() interface{} {
+    m := make(map[string]interface{})
+    if dh.rh == nil || dh.rh.ServerConfig == nil {
+        return m
+    }
+    v := reflect.ValueOf(dh.rh.ServerConfig).Elem()
+    t := v.Type()
+    for i := 0; i < t.NumField(); i++ {
+        f := t.Field(i)
+        if f.PkgPath != "" {
+            continue
+        }
+        m[f.Name] = debugValue(v.Field(i))
+    }
+    return m
+}
+
+func debugValue(v reflect.Value) interface{} {
+    if d, ok := v.Interface().(time.Duration); ok {
+        return d.String()
+    }
+    switch v.Kind() {
+    case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+        reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return v.Interface()
+    case reflect.Ptr, reflect.Interface, reflect.Func, reflect.Map, reflect.Slice, reflect.Chan:
+        if v.IsNil() {
+            return nil
+        }
+        if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+            return fmt.Sprintf("%s (len %d)", v.Type(), v.Len())
+        }
+        if v.Kind() == reflect.Interface {
+            return fmt.Sprintf("%T", v.Interface())
+        }
+    }
+    return v.Type().String()
+}
+
+func (dh *DebugHandler) templates() interface{} {
+    m := make(map[string]interface{})
+    if dh.rh == nil {
+        return m
+    }
+    if mte, ok := dh.rh.TemplateEnginer.(*MultiTemplateEngine); ok {
+        engines := make(map[string]interface{})
+        for _, ext := range mte.Exts() {
+            engines[ext] = debugTemplateEngine(mte.Engine(ext))
+        }
+        m["TemplateEngines"] = engines
+    } else if dh.rh.TemplateEnginer != nil {
+        m["TemplateEngine"] = debugTemplateEngine(dh.rh.TemplateEnginer)
+    }
+    if ve, ok := dh.rh.ViewEnginer.(*DefaultViewEngine); ok {
+        m["ViewEngine"] = map[string]interface{}{
+            "UseCache": ve.UseCache,
+            "Cached":   ve.CachedViews(),
+        }
+    } else if dh.rh.ViewEnginer != nil {
+        m["ViewEngine"] = fmt.Sprintf("%T", dh.rh.ViewEnginer)
+    }
+    return m
+}
+
+func debugTemplateEngine(te TemplateEnginer) interface{} {
+    if dte, ok := te.(*DefaultTemplateEngine); ok {
+        return map[string]interface{}{
+            "UseCache": dte.UseCache,
+            "Cached":   dte.CachedTemplates(),
+        }
+    }
+    return fmt.Sprintf("%T", te)
+}
+
+func debugRuntime() interface{} {
+    var ms runtime.MemStats
+    runtime.ReadMemStats(&ms)
+    return map[string]interface{}{
+        "GoVersion":    runtime.Version(),
+        "NumCPU":       runtime.NumCPU(),
+        "GOMAXPROCS":   runtime.GOMAXPROCS(0),
+        "NumGoroutine": runtime.NumGoroutine(),
+        "Uptime":       time.Since(processStartTime).String(),
+        "Memory": map[string]interface{}{
+            "Alloc":        ms.Alloc,
+            "TotalAlloc":   ms.TotalAlloc,
+            "Sys":          ms.Sys,
+            "HeapAlloc":    ms.HeapAlloc,
+            "HeapInuse":    ms.HeapInuse,
+            "HeapObjects":  ms.HeapObjects,
+            "NumGC":        ms.NumGC,
+            "PauseTotalNs": ms.PauseTotalNs,
+        },
+    }
+}
+
+func writeDebugJson(w http.ResponseWriter, v interface{}) {
+    b, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json; charset=utf-8")
+    w.Write(b)
+}
+
+func isLoopbackRequest(r *http.Request) bool {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        host = r.RemoteAddr
+    }
+    ip := net.ParseIP(host)
+    return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,69 @@
+package goku
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestDebugHandler(t *testing.T) {
+    Controller("debugtest").
+        Get("index", func(ctx *HttpContext) ActionResulter {
+        return ctx.Raw("ok")
+    }).Filters(&BaseFilter{})
+    rh := createTestHandler(&ServerConfig{DebugPath: "/_debug", ReadTimeout: time.Second})
+    rh.debugHandler = CreateDebugHandler("/_debug/", rh)
+
+    get := func(url, remoteAddr string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", url, nil)
+        req.RemoteAddr = remoteAddr
+        rh.ServeHTTP(w, req)
+        return w
+    }
+
+    // only the loopback requests by default
+    w := get("/_debug/routes", "10.0.0.1:1234")
+    assert.Equals(t, w.Code, http.StatusNotFound)
+
+    w = get("/_debug/", "127.0.0.1:1234")
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.True(t, strings.Contains(w.Body.String(), `href="/_debug/pprof/"`))
+
+    w = get("/_debug/routes", "127.0.0.1:1234")
+    var routes []map[string]interface{}
+    json.Unmarshal(w.Body.Bytes(), &routes)
+    assert.Equals(t, len(routes), 1)
+    assert.Equals(t, routes[0]["Pattern"], "/{controller}/{action}")
+
+    w = get("/_debug/controllers", "[::1]:1234")
+    assert.True(t, strings.Contains(w.Body.String(), `"Name": "debugtest"`))
+    assert.True(t, strings.Contains(w.Body.String(), `"*goku.BaseFilter"`))
+
+    w = get("/_debug/config", "127.0.0.1:1234")
+    var conf map[string]interface{}
+    json.Unmarshal(w.Body.Bytes(), &conf)
+    assert.Equals(t, conf["DebugPath"], "/_debug")
+    assert.Equals(t, conf["ReadTimeout"], "1s")
+    assert.Equals(t, conf["Logger"], nil)
+
+    w = get("/_debug/pprof/", "127.0.0.1:1234")
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.True(t, strings.Contains(w.Body.String(), "goroutine"))
+    w = get("/_debug/pprof/goroutine?debug=1", "127.0.0.1:1234")
+    assert.True(t, strings.Contains(w.Body.String(), "goroutine profile"))
+
+    w = get("/_debug/runtime", "127.0.0.1:1234")
+    assert.True(t, strings.Contains(w.Body.String(), `"NumGoroutine"`))
+
+    // custom auth
+    rh.debugHandler.Auth = func(r *http.Request) bool {
+        return r.Header.Get("X-Debug-Token") == "secret"
+    }
+    w = get("/_debug/runtime", "127.0.0.1:1234")
+    assert.Equals(t, w.Code, http.StatusNotFound)
+}
@@ -2,51 +2,206 @@ package goku
 
 import (
     //"fmt"
+    "bufio"
+    "errors"
+    "fmt"
     "html/template"
     "net/http"
+    "net/url"
     "os"
     "path"
     "runtime"
+    "sort"
+    "strings"
 )
 
 type devErrorContext struct {
     ShowDetail bool
     Request    *http.Request
+    RequestId  string
     Err        string
     StatusCode int
     Stack      string
 
-    OsEnviron      []string
+    Frames        []*devStackFrame
+    TemplateError *devTemplateError
+    RouteData     *RouteData
+    Form          url.Values
+    Headers       []devKeyValue
+    Cookies       []*http.Cookie
+    ViewData      map[string]interface{}
+    Data          map[string]interface{}
+
+    OsEnviron      []devKeyValue
     GoRoot         string
     GoNumGoroutine int
     GoVersion      string
     GokuVersion    string
 }
 
+type devKeyValue struct {
+    Key, Value string
+}
+
+// a line of the source file
+type devSourceLine struct {
+    Number  int
+    Code    string
+    Current bool
+}
+
+type devStackFrame struct {
+    StackFrame
+    Source []devSourceLine // only for the user's frames
+}
+
+type devTemplateError struct {
+    File    string
+    Line    int
+    Message string
+    Source  []devSourceLine
+}
+
+// the lines around the line of the file
+func readSourceLines(file string, line, around int) []devSourceLine {
+    f, err := os.Open(file)
+    if err != nil {
+        return nil
+    }
+    defer f.Close()
+    var lines []devSourceLine
+    scanner := bufio.NewScanner(f)
+    for n := 1; scanner.Scan() && n <= line+around; n++ {
+        if n >= line-around {
+            lines = append(lines, devSourceLine{n, scanner.Text(), n == line})
+        }
+    }
+    return lines
+}
+
+// the names of the environment variables which value is a secret
+var secretEnvNames = []string{"SECRET", "PASSWORD", "PASSWD", "TOKEN", "KEY", "CREDENTIAL", "PRIVATE", "AUTH", "DSN", "SESSION", "COOKIE"}
+
+func isSecretName(name string) bool {
+    name = strings.ToUpper(name)
+    for _, s := range secretEnvNames {
+        if strings.Contains(name, s) {
+            return true
+        }
+    }
+    return false
+}
+
+func redactedEnviron() []devKeyValue {
+    env := os.Environ()
+    kvs := make([]devKeyValue, 0, len(env))
+    for _, e := range env {
+        kv := strings.SplitN(e, "=", 2)
+        if len(kv) != 2 {
+            continue
+        }
+        if isSecretName(kv[0]) && kv[1] != "" {
+            kv[1] = "******"
+        }
+        kvs = append(kvs, devKeyValue{kv[0], kv[1]})
+    }
+    sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+    return kvs
+}
+
+func redactedHeaders(h http.Header) []devKeyValue {
+    kvs := make([]devKeyValue, 0, len(h))
+    for name, values := range h {
+        v := strings.Join(values, ", ")
+        if name == "Authorization" || name == "Cookie" || name == "Proxy-Authorization" {
+            v = "******"
+        }
+        kvs = append(kvs, devKeyValue{name, v})
+    }
+    sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+    return kvs
+}
+
+// a copy of the form, the request's form is not changed
+func redactedForm(form url.Values) url.Values {
+    rf := make(url.Values, len(form))
+    for name, values := range form {
+        if isSecretName(name) {
+            values = []string{"******"}
+        }
+        rf[name] = values
+    }
+    return rf
+}
+
+func redactedCookies(cookies []*http.Cookie) []*http.Cookie {
+    for _, c := range cookies {
+        if isSecretName(c.Name) {
+            c.Value = "******"
+        }
+    }
+    return cookies
+}
+
 type devErrorHanller struct {
     view            string
     TemplateEnginer TemplateEnginer
 }
 
-func (eh *devErrorHanller) showErrorInfo(ctx *HttpContext, err string, statusCode int, showDetail bool, stack string) {
+func (eh *devErrorHanller) showErrorInfo(ctx *HttpContext, er *devErrorResult) {
     ec := &devErrorContext{
-        ShowDetail:  showDetail,
+        ShowDetail:  er.ShowDetail,
         Request:     ctx.Request,
-        Err:         err,
-        StatusCode:  statusCode,
+        RequestId:   ctx.requestId,
+        Err:         er.Err,
+        StatusCode:  er.StatusCode,
+        RouteData:   ctx.RouteData,
         GoVersion:   runtime.Version(),
         GokuVersion: GetVersion(),
     }
-    if showDetail {
-        ec.Stack = stack
-        ec.OsEnviron = os.Environ()
+    if er.ShowDetail {
+        ec.Stack = er.Stack
+        for _, f := range er.Frames {
+            df := &devStackFrame{StackFrame: f}
+            if f.User {
+                df.Source = readSourceLines(f.File, f.Line, 5)
+            }
+            ec.Frames = append(ec.Frames, df)
+        }
+        var te *TemplateError
+        if errors.As(er.Cause, &te) {
+            file, line := te.Location()
+            ec.TemplateError = &devTemplateError{
+                File:    file,
+                Line:    line,
+                Message: te.Err.Error(),
+            }
+            if line > 0 {
+                ec.TemplateError.Source = readSourceLines(file, line, 5)
+            }
+        }
+        // the form is parsed before the action
+        form := ctx.Request.Form
+        if form == nil {
+            form = ctx.Request.URL.Query()
+        }
+        ec.Form = redactedForm(form)
+        ec.Headers = redactedHeaders(ctx.Request.Header)
+        ec.Cookies = redactedCookies(ctx.Request.Cookies())
+        ec.ViewData = ctx.ViewData
+        ec.Data = ctx.Data
+        ec.OsEnviron = redactedEnviron()
         ec.GoRoot = runtime.GOROOT()
         ec.GoNumGoroutine = runtime.NumGoroutine()
     }
 
     vd := &ViewData{Model: ec}
     ctx.SetHeader("Content-Type", "text/html")
-    eh.TemplateEnginer.Render(eh.view, "", vd, ctx.responseContentCache)
+    if err := eh.TemplateEnginer.Render(eh.view, "", vd, ctx.responseContentCache); err != nil {
+        // the error page itself is broken, show the error in plain text
+        ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+        fmt.Fprintf(ctx.responseContentCache, "%s\n\nrender the error page error: %s", ec.Err, err)
+    }
 }
 
 func createDevErrorHandler() *devErrorHanller {
@@ -70,10 +225,12 @@ type devErrorResult struct {
     Err        string
     ShowDetail bool
     Stack      string
+    Frames     []StackFrame // the stack of the panic
+    Cause      error        // the error or the panic value
 }
 
 func (er *devErrorResult) ExecuteResult(ctx *HttpContext) {
     ctx.responseContentCache.Reset()
     ctx.Status(er.StatusCode)
-    devErrorHanlle.showErrorInfo(ctx, er.Err, er.StatusCode, er.ShowDetail, er.Stack)
+    devErrorHanlle.showErrorInfo(ctx, er)
 }
@@ -0,0 +1,103 @@
+package goku
+
+import (
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path"
+    "runtime"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func panicInDevPage() {
+    var m map[string]int
+    m["a"] = 1
+}
+
+func TestDevErrorPage(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-devview")
+    defer os.RemoveAll(dir)
+    os.MkdirAll(path.Join(dir, "devtest"), 0755)
+    ioutil.WriteFile(path.Join(dir, "devtest", "broken.html"),
+        []byte("<h1>title</h1>\n{{if .Model}}\n{{.Model.Name}\n{{end}}\n"), 0644)
+
+    os.Setenv("GOKU_TEST_API_SECRET", "s3cr3t" + "-value")
+    defer os.Unsetenv("GOKU_TEST_API_SECRET")
+
+    Controller("devtest").
+        Get("panic", func(ctx *HttpContext) ActionResulter {
+        ctx.ViewData["Title"] = "the title"
+        panicInDevPage()
+        return nil
+    }).
+        Get("broken", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(map[string]string{"Name": "goku"})
+    })
+
+    sc := &ServerConfig{Debug: true}
+    rh := createTestHandler(sc)
+    rh.TemplateEnginer = CreateDefaultTemplateEngine(false)
+    rh.ViewEnginer = CreateDefaultViewEngine(dir, "", "", false)
+
+    get := func(url string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", url, nil)
+        req.Header.Set("Accept", "text/html")
+        req.Header.Set("Authorization", "Bearer abc" + "123")
+        req.AddCookie(&http.Cookie{Name: "session_id", Value: "sid" + "-value"})
+        req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+        rh.ServeHTTP(w, req)
+        return w
+    }
+
+    w := get("/devtest/panic?page=2&password=pa55" + "-value")
+    body := w.Body.String()
+    assert.Equals(t, w.Code, http.StatusInternalServerError)
+    // the source of the failing line
+    assert.True(t, strings.Contains(body, `<div class="func">github.com/QLeelulu/goku.panicInDevPage</div>`))
+    _, file, _, _ := runtime.Caller(0)
+    assert.True(t, strings.Contains(body, `<div class="file">`+file+`:17</div>`))
+    assert.True(t, strings.Contains(body, `<div class="current"><span class="ln">17</span>`))
+    assert.True(t, strings.Contains(body, `m[&#34;a&#34;] = 1`))
+    // the request inspector
+    assert.True(t, strings.Contains(body, "/{controller}/{action}"))
+    assert.True(t, strings.Contains(body, "<td>devtest</td>"))
+    assert.True(t, strings.Contains(body, "<td>2</td>"))
+    assert.True(t, strings.Contains(body, "the title"))
+    assert.True(t, strings.Contains(body, "<td>dark</td>"))
+    // the secrets are redacted
+    assert.True(t, strings.Contains(body, "GOKU_TEST_API_SECRET"))
+    assert.True(t, !strings.Contains(body, "s3cr3t-value"))
+    assert.True(t, !strings.Contains(body, "abc123"))
+    assert.True(t, !strings.Contains(body, "sid-value"))
+    assert.True(t, strings.Contains(body, `<td class="t">password</td><td>******</td>`))
+    assert.True(t, !strings.Contains(body, "pa55-value"))
+
+    // the template parse error location
+    w = get("/devtest/broken")
+    body = w.Body.String()
+    assert.Equals(t, w.Code, http.StatusInternalServerError)
+    assert.True(t, strings.Contains(body, "Template Error"))
+    assert.True(t, strings.Contains(body, path.Join(dir, "devtest", "broken.html")+":3"))
+    assert.True(t, strings.Contains(body, `<div class="current"><span class="ln">3</span>{{.Model.Name}</div>`))
+}
+
+func TestTemplateErrorLocation(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-tmplerr")
+    defer os.RemoveAll(dir)
+    file := path.Join(dir, "index.html")
+    ioutil.WriteFile(file, []byte("line 1\n{{.Name}\n"), 0644)
+
+    te := CreateDefaultTemplateEngine(false)
+    err := te.Render(file, "", &ViewData{}, ioutil.Discard)
+    tErr, ok := err.(*TemplateError)
+    assert.True(t, ok)
+    assert.True(t, tErr.Parse)
+    f, line := tErr.Location()
+    assert.Equals(t, f, file)
+    assert.Equals(t, line, 2)
+    assert.True(t, strings.HasPrefix(tErr.Error(), "parse template"))
+}
@@ -0,0 +1,251 @@
+package goku
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// ErrorInfo is the info about an error response,
+// it is the model of the error view
+type ErrorInfo struct {
+    StatusCode int    `json:"status"`
+    Message    string `json:"message"`
+    Err        error  `json:"-"` // the error caused the response, may be nil
+    RequestId  string `json:"request_id"`
+    Path       string `json:"-"`
+
+    Details interface{} `json:"details,omitempty"` // from HTTPError.Details
+}
+
+// StatusText gets the text of the status code, e.g. "Not Found"
+func (ei *ErrorInfo) StatusText() string {
+    return http.StatusText(ei.StatusCode)
+}
+
+// ErrorHandler makes the error response,
+// returns nil to use the next handler or the default response
+type ErrorHandler func(ctx *HttpContext, ei *ErrorInfo) ActionResulter
+
+type errorTypeHandler struct {
+    typ     reflect.Type
+    handler ErrorHandler
+}
+
+// ErrorHandlers holds the error handlers keyed by status code and error type,
+// set it to ServerConfig.ErrorHandlers:
+//      config.ErrorHandlers = goku.CreateErrorHandlers().
+//          Status(404, goku.ViewErrorHandler("404")).  // views/shared/404.html
+//          Status(0, goku.ViewErrorHandler("error")).  // all the other status codes
+//          Type((*mysql.MySQLError)(nil), func(ctx *goku.HttpContext, ei *goku.ErrorInfo) goku.ActionResulter {
+//              ei.Message = "database error"
+//              return nil
+//          })
+// the type handlers run first, then the status code handlers.
+// they are not used in debug mode, the dev error page is shown instead
+type ErrorHandlers struct {
+    byStatus map[int]ErrorHandler
+    byType   []errorTypeHandler
+}
+
+func CreateErrorHandlers() *ErrorHandlers {
+    return &ErrorHandlers{byStatus: make(map[int]ErrorHandler)}
+}
+
+// Status sets the handler of the status code, 0 for all the status codes
+func (eh *ErrorHandlers) Status(code int, h ErrorHandler) *ErrorHandlers {
+    eh.byStatus[code] = h
+    return eh
+}
+
+// Type sets the handler of the errors have the same type as target,
+// the wrapped errors are checked too
+func (eh *ErrorHandlers) Type(target error, h ErrorHandler) *ErrorHandlers {
+    eh.byType = append(eh.byType, errorTypeHandler{reflect.TypeOf(target), h})
+    return eh
+}
+
+// the type handlers for the error, in the order to try
+func (eh *ErrorHandlers) lookupType(ei *ErrorInfo) []ErrorHandler {
+    var hs []ErrorHandler
+    for err := ei.Err; err != nil; err = errors.Unwrap(err) {
+        t := reflect.TypeOf(err)
+        for _, th := range eh.byType {
+            if th.typ == t {
+                hs = append(hs, th.handler)
+            }
+        }
+    }
+    return hs
+}
+
+// the status code handlers for the error, in the order to try,
+// looked up after the type handlers, which may change the StatusCode
+func (eh *ErrorHandlers) lookupStatus(ei *ErrorInfo) []ErrorHandler {
+    var hs []ErrorHandler
+    if h, ok := eh.byStatus[ei.StatusCode]; ok {
+        hs = append(hs, h)
+    }
+    if h, ok := eh.byStatus[0]; ok {
+        hs = append(hs, h)
+    }
+    return hs
+}
+
+// ViewErrorHandler renders the view with the ErrorInfo as the model,
+// the view is found like ctx.Render, e.g. "404" for views/shared/404.html.
+// the json requests get the default json response
+func ViewErrorHandler(viewName string) ErrorHandler {
+    return func(ctx *HttpContext, ei *ErrorInfo) ActionResulter {
+        if ctx.WantsJson() {
+            return nil
+        }
+        vr := ctx.Render(viewName, ei)
+        vr.StatusCode = ei.StatusCode
+        return vr
+    }
+}
+
+// ErrorResult is the result of ctx.Error, ctx.NotFound and the errors returned from the actions,
+// the response is made by the ServerConfig.ErrorHandlers
+type ErrorResult struct {
+    ErrorInfo
+}
+
+func (er *ErrorResult) ExecuteResult(ctx *HttpContext) {
+    er.RequestId = ctx.requestId
+    er.Path = ctx.Request.URL.Path
+    sc := ctx.requestHandler.ServerConfig
+    if sc.Debug {
+        der := &devErrorResult{
+            StatusCode: er.StatusCode,
+            Err:        er.Message,
+            ShowDetail: true,
+            Cause:      er.Err,
+        }
+        // show the internal error to the developer
+        if er.Err != nil {
+            der.Err = er.Err.Error()
+        }
+        der.ExecuteResult(ctx)
+        return
+    }
+    if sc.ErrorHandlers != nil {
+        for _, h := range sc.ErrorHandlers.lookupType(&er.ErrorInfo) {
+            if er.executeHandler(ctx, h) {
+                return
+            }
+        }
+        for _, h := range sc.ErrorHandlers.lookupStatus(&er.ErrorInfo) {
+            if er.executeHandler(ctx, h) {
+                return
+            }
+        }
+    }
+    er.executeDefault(ctx)
+}
+
+// returns false if the handler returns nil or panic
+func (er *ErrorResult) executeHandler(ctx *HttpContext, h ErrorHandler) (ok bool) {
+    defer func() {
+        if e := recover(); e != nil {
+            ctx.Logger().Error("error handler panic", "error", e)
+            ctx.responseContentCache.Reset()
+            ok = false
+        }
+    }()
+    ar := h(ctx, &er.ErrorInfo)
+    if ar == nil {
+        return false
+    }
+    // the error of the error view is not handled by the handlers again
+    if vr, ok := ar.(*ViewResult); ok {
+        if err := vr.renderBody(ctx); err != nil {
+            ctx.Logger().Error("render error view error", "error", err)
+            return false
+        }
+        vr.ActionResult.ExecuteResult(ctx)
+        return true
+    }
+    ar.ExecuteResult(ctx)
+    return true
+}
+
+// json for the json requests, plain text for the others
+func (er *ErrorResult) executeDefault(ctx *HttpContext) {
+    ctx.responseContentCache.Reset()
+    ctx.Status(er.StatusCode)
+    if ctx.WantsJson() {
+        body := map[string]interface{}{
+            "status":     er.StatusCode,
+            "error":      er.StatusText(),
+            "message":    er.Message,
+            "request_id": er.RequestId,
+        }
+        if er.Details != nil {
+            body["details"] = er.Details
+        }
+        b, _ := json.Marshal(body)
+        ctx.SetHeader("Content-Type", "application/json; charset=utf-8")
+        ctx.Write(b)
+        return
+    }
+    ctx.SetHeader("Content-Type", "text/plain; charset=utf-8")
+    ctx.WriteBuffer(bytes.NewBufferString(er.Message))
+}
+
+func createErrorResult(statusCode int, err interface{}) *ErrorResult {
+    var he *HTTPError
+    if e, ok := err.(error); ok && errors.As(e, &he) {
+        return errorResultOf(e)
+    }
+    er := &ErrorResult{ErrorInfo{
+        StatusCode: statusCode,
+        Message:    fmt.Sprintf("%v", err),
+    }}
+    if e, ok := err.(error); ok {
+        er.Err = e
+    }
+    return er
+}
+
+// WantsJson gets whether the client prefers json to html,
+// by the Accept header, or the ajax request not accept html
+func (ctx *HttpContext) WantsJson() bool {
+    accept := ctx.GetHeader("Accept")
+    html := strings.Index(accept, "text/html")
+    jsonIndex := strings.Index(accept, "application/json")
+    if jsonIndex < 0 {
+        jsonIndex = strings.Index(accept, "+json")
+    }
+    if jsonIndex >= 0 {
+        return html < 0 || jsonIndex < html
+    }
+    return html < 0 && ctx.IsAjax()
+}
+
+// load the error views conf, the key is the status code, 0 for all:
+//      "ErrorViews": { "404": "404", "0": "error" }
+func loadErrorViewsConf(v interface{}, eh *ErrorHandlers) *ErrorHandlers {
+    m, ok := v.(map[string]interface{})
+    if !ok {
+        log.Fatalln("conf file error: wrong ErrorViews format.")
+    }
+    if eh == nil {
+        eh = CreateErrorHandlers()
+    }
+    for code, view := range m {
+        c, err := strconv.Atoi(code)
+        if err != nil {
+            log.Fatalln("conf file error: wrong status code of ErrorViews,", code)
+        }
+        eh.Status(c, ViewErrorHandler(view.(string)))
+    }
+    return eh
+}
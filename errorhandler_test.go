@@ -0,0 +1,128 @@
+package goku
+
+import (
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+type testDBError struct {
+    Code int
+}
+
+func (e *testDBError) Error() string {
+    return fmt.Sprintf("db error %d", e.Code)
+}
+
+func TestErrorHandlers(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-errorview")
+    defer os.RemoveAll(dir)
+    os.MkdirAll(path.Join(dir, "shared"), 0755)
+    ioutil.WriteFile(path.Join(dir, "shared", "404.html"),
+        []byte(`<h1>{{.Model.StatusCode}} {{.Model.StatusText}}</h1><p>{{.Model.Message}}</p>`), 0644)
+    ioutil.WriteFile(path.Join(dir, "shared", "broken.html"), []byte(`{{.Model.NoSuchField}}`), 0644)
+
+    Controller("errortest").
+        Get("db", func(ctx *HttpContext) ActionResulter {
+        return ctx.Error(fmt.Errorf("query blog: %w", &testDBError{Code: 1040}))
+    }).
+        Get("fail", func(ctx *HttpContext) ActionResulter {
+        return ctx.Error(errors.New("secret internal detail"))
+    }).
+        Get("panic", func(ctx *HttpContext) ActionResulter {
+        panic("boom")
+    }).
+        Get("notfound", func(ctx *HttpContext) ActionResulter {
+        return ctx.NotFound("no such blog")
+    })
+
+    sc := &ServerConfig{
+        ErrorHandlers: CreateErrorHandlers().
+            Status(http.StatusNotFound, ViewErrorHandler("404")).
+            Status(http.StatusInternalServerError, ViewErrorHandler("broken")).
+            Status(http.StatusServiceUnavailable, func(ctx *HttpContext, ei *ErrorInfo) ActionResulter {
+            return ctx.Raw("503: " + ei.Message)
+        }).
+            Type((*testDBError)(nil), func(ctx *HttpContext, ei *ErrorInfo) ActionResulter {
+            ei.StatusCode = http.StatusServiceUnavailable
+            ei.Message = "database is busy"
+            return nil
+        }),
+    }
+    rh := createTestHandler(sc)
+    rh.TemplateEnginer = CreateDefaultTemplateEngine(false)
+    rh.ViewEnginer = CreateDefaultViewEngine(dir, "", "", false)
+
+    get := func(url, accept string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", url, nil)
+        req.Header.Set("Accept", accept)
+        rh.ServeHTTP(w, req)
+        return w
+    }
+
+    // unmatched route, rendered by the view
+    w := get("/a/b/c/d", "text/html")
+    assert.Equals(t, w.Code, http.StatusNotFound)
+    assert.True(t, strings.Contains(w.Body.String(), "<h1>404 Not Found</h1>"))
+
+    // missing action
+    w = get("/errortest/none", "text/html,application/xhtml+xml")
+    assert.Equals(t, w.Code, http.StatusNotFound)
+    assert.True(t, strings.Contains(w.Body.String(), "No [GET] Action"))
+
+    // json for the api requests
+    w = get("/errortest/none", "application/json")
+    assert.Equals(t, w.Code, http.StatusNotFound)
+    assert.Equals(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+    assert.True(t, strings.Contains(w.Body.String(), `"status":404`))
+    assert.True(t, strings.Contains(w.Body.String(), `"request_id":"`))
+
+    // by the error type, then by the status code it changed to
+    w = get("/errortest/db", "text/html")
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.Equals(t, w.Body.String(), "503: database is busy")
+
+    // the view fails, so the default response, without the error text
+    w = get("/errortest/fail", "text/html")
+    assert.Equals(t, w.Code, http.StatusInternalServerError)
+    assert.Equals(t, w.Header().Get("Content-Type"), "text/plain; charset=utf-8")
+    assert.Equals(t, w.Body.String(), "Internal Server Error")
+
+    // ctx.NotFound of the action, by the custom 404 handler
+    w = get("/errortest/notfound", "text/html")
+    assert.Equals(t, w.Code, http.StatusNotFound)
+    assert.True(t, strings.Contains(w.Body.String(), "<h1>404 Not Found</h1><p>no such blog</p>"))
+
+    // panic is not shown to the client
+    w = get("/errortest/panic", "application/json")
+    assert.Equals(t, w.Code, http.StatusInternalServerError)
+    assert.True(t, strings.Contains(w.Body.String(), `"message":"Internal Server Error"`))
+    assert.True(t, !strings.Contains(w.Body.String(), "boom"))
+}
+
+func TestWantsJson(t *testing.T) {
+    cases := []struct {
+        accept, xrw string
+        expected    bool
+    }{
+        {"application/json", "", true},
+        {"application/problem+json", "", true},
+        {"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "", false},
+        {"application/json, text/javascript, */*; q=0.01", "XMLHttpRequest", true},
+        {"text/html, */*; q=0.01", "XMLHttpRequest", false},
+        {"*/*", "XMLHttpRequest", true},
+        {"*/*", "", false},
+    }
+    for _, c := range cases {
+        ctx, _ := createTestContext("GET", "/", map[string]string{"Accept": c.accept, "X-Requested-With": c.xrw})
+        assert.Equals(t, ctx.WantsJson(), c.expected)
+    }
+}
@@ -0,0 +1,85 @@
+package goku
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "net/http"
+    "strings"
+)
+
+// ETagMiddleware computes the ETag from the buffered response content,
+// and replaces the response with 304 Not Modified
+// if the client's copy is still fresh.
+// only the GET & HEAD requests with status code 200 are handled,
+// static files are handled by http.ServeFile self.
+type ETagMiddleware struct {
+    BaseMiddleware
+    Weak bool // generate weak ETag, like W/"..."
+}
+
+func (em *ETagMiddleware) OnEndRequest(ctx *HttpContext) (ActionResulter, error) {
+    if ctx.Method != "GET" && ctx.Method != "HEAD" {
+        return nil, nil
+    }
+    if ctx.RouteData != nil && ctx.RouteData.Route.IsStatic {
+        return nil, nil
+    }
+    if ctx.responseStatusCode != 0 && ctx.responseStatusCode != http.StatusOK {
+        return nil, nil
+    }
+    etag := ctx.Header().Get("ETag")
+    if etag == "" && ctx.responseContentCache.Len() > 0 {
+        etag = computeETag(ctx.responseContentCache.Bytes(), em.Weak)
+        ctx.SetHeader("ETag", etag)
+    }
+    if isNotModified(ctx, etag) {
+        ctx.responseContentCache.Reset()
+        return ctx.NotModified(), nil
+    }
+    return nil, nil
+}
+
+func computeETag(content []byte, weak bool) string {
+    sum := sha1.Sum(content)
+    etag := "\"" + hex.EncodeToString(sum[:]) + "\""
+    if weak {
+        etag = "W/" + etag
+    }
+    return etag
+}
+
+// check the request's conditional headers.
+// If-None-Match take precedence over If-Modified-Since
+func isNotModified(ctx *HttpContext, etag string) bool {
+    if inm := ctx.GetHeader("If-None-Match"); inm != "" {
+        if etag == "" {
+            return false
+        }
+        for _, v := range strings.Split(inm, ",") {
+            v = strings.TrimSpace(v)
+            if v == "*" || weakETagEqual(v, etag) {
+                return true
+            }
+        }
+        return false
+    }
+    lm := ctx.Header().Get("Last-Modified")
+    ims := ctx.GetHeader("If-Modified-Since")
+    if lm == "" || ims == "" {
+        return false
+    }
+    lmt, err := http.ParseTime(lm)
+    if err != nil {
+        return false
+    }
+    imst, err := http.ParseTime(ims)
+    if err != nil {
+        return false
+    }
+    return !lmt.After(imst)
+}
+
+// weak comparison, see RFC 7232 section 2.3.2
+func weakETagEqual(a, b string) bool {
+    return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
@@ -0,0 +1,46 @@
+package goku
+
+import (
+    "net/http"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestETagMiddleware(t *testing.T) {
+    em := &ETagMiddleware{Weak: true}
+    ctx, w := createTestContext("GET", "/blog/1", nil)
+    ctx.WriteString("hello goku")
+    ar, _ := em.OnEndRequest(ctx)
+    assert.Equals(t, ar, nil)
+    etag := w.Header().Get("ETag")
+    assert.Equals(t, etag[:3], "W/\"")
+
+    ctx, w = createTestContext("GET", "/blog/1", map[string]string{"If-None-Match": `"abc", ` + etag[2:]})
+    ctx.WriteString("hello goku")
+    ar, _ = em.OnEndRequest(ctx)
+    ar.ExecuteResult(ctx)
+    ctx.flushToResponse()
+    assert.Equals(t, w.Code, http.StatusNotModified)
+    assert.Equals(t, w.Body.Len(), 0)
+
+    // changed content
+    ctx, _ = createTestContext("GET", "/blog/1", map[string]string{"If-None-Match": etag})
+    ctx.WriteString("hello goku!")
+    ar, _ = em.OnEndRequest(ctx)
+    assert.Equals(t, ar, nil)
+}
+
+func TestLastModified(t *testing.T) {
+    updateAt := time.Date(2012, 10, 1, 8, 0, 0, 0, time.UTC)
+    ctx, w := createTestContext("GET", "/blog/1", map[string]string{
+        "If-Modified-Since": updateAt.Format(http.TimeFormat),
+    })
+    assert.Equals(t, ctx.LastModified(updateAt), true)
+    assert.Equals(t, w.Header().Get("Last-Modified"), updateAt.Format(http.TimeFormat))
+
+    ctx, _ = createTestContext("GET", "/blog/1", map[string]string{
+        "If-Modified-Since": updateAt.Format(http.TimeFormat),
+    })
+    assert.Equals(t, ctx.LastModified(updateAt.Add(time.Hour)), false)
+}
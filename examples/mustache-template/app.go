@@ -40,7 +40,8 @@ func main() {
     /**
      * set template engine to mustache
      */
-    config.TemplateEnginer = mustache.NewMustacheTemplateEngine()
+    // the mustache engine panics on the errors, wrap it to return the errors
+    config.TemplateEnginer = goku.WrapTemplateEnginer(mustache.NewMustacheTemplateEngine())
 
     config.LogLevel = goku.LOG_LEVEL_LOG
 
@@ -53,3 +53,23 @@ func runFilterResultExecuted(ctx *HttpContext, filters []Filter) (ar ActionResul
 	}
 	return
 }
+
+// BaseFilter implements all the methods of Filter and does nothing,
+// embed it in your filter and override the methods you need.
+type BaseFilter struct{}
+
+func (bf *BaseFilter) OnActionExecuting(ctx *HttpContext) (ActionResulter, error) {
+	return nil, nil
+}
+
+func (bf *BaseFilter) OnActionExecuted(ctx *HttpContext) (ActionResulter, error) {
+	return nil, nil
+}
+
+func (bf *BaseFilter) OnResultExecuting(ctx *HttpContext) (ActionResulter, error) {
+	return nil, nil
+}
+
+func (bf *BaseFilter) OnResultExecuted(ctx *HttpContext) (ActionResulter, error) {
+	return nil, nil
+}
@@ -0,0 +1,127 @@
+package form
+
+import (
+    "mime/multipart"
+    "net/http"
+    "strconv"
+    "strings"
+)
+
+const (
+    MSG_FILE_MAX_SIZE  = "file size must less than {0} bytes"
+    MSG_FILE_MIME_TYPE = "file type must be one of {0}"
+)
+
+// FileField is the field for the uploaded file,
+// the clean value is the *multipart.FileHeader.
+// error msg keys:
+//      required
+//      max_size
+//      mime_type
+//      invalid
+type FileField struct {
+    BaseField
+    file      *multipart.FileHeader
+    maxSize   int64
+    mimeTypes []string
+}
+
+func NewFileField(name string, nickname string, required bool) *FileField {
+    ff := &FileField{}
+    ff.init(name, nickname, required)
+    return ff
+}
+
+// SetFile sets the uploaded file
+func (ff *FileField) SetFile(fh *multipart.FileHeader) {
+    ff.file = fh
+    if fh != nil {
+        ff.value = fh.Filename
+    } else {
+        ff.value = ""
+    }
+}
+
+// File gets the uploaded file, nil if no file uploaded
+func (ff *FileField) File() *multipart.FileHeader {
+    return ff.file
+}
+
+// The return value is the FileField, so calls can be chained
+func (ff *FileField) MaxSize(n int64) *FileField {
+    ff.maxSize = n
+    return ff
+}
+
+// MimeTypes sets the allowed mime types, like "image/png" or "image/*".
+// the mime type is detected by the file content, not the one send by the client.
+// The return value is the FileField, so calls can be chained
+func (ff *FileField) MimeTypes(types ...string) *FileField {
+    ff.mimeTypes = types
+    return ff
+}
+
+func (ff *FileField) Valid() *ValidResult {
+    vr := &ValidResult{}
+    opt := ff.option
+    if ff.file == nil {
+        if opt.Required {
+            vr.ErrorMsg = getOrDefault(opt.ErrorMsgs, "required", MSG_REQUIRED)
+        } else {
+            vr.IsValid = true
+        }
+    } else if ff.maxSize > 0 && ff.file.Size > ff.maxSize {
+        vr.ErrorMsg = strings.Replace(
+            getOrDefault(opt.ErrorMsgs, "max_size", MSG_FILE_MAX_SIZE), "{0}", strconv.FormatInt(ff.maxSize, 10), -1)
+    } else if len(ff.mimeTypes) > 0 {
+        mimeType, err := detectMimeType(ff.file)
+        if err != nil {
+            vr.ErrorMsg = getOrDefault(opt.ErrorMsgs, "invalid", MSG_INVALID)
+        } else if !matchMimeType(mimeType, ff.mimeTypes) {
+            vr.ErrorMsg = strings.Replace(
+                getOrDefault(opt.ErrorMsgs, "mime_type", MSG_FILE_MIME_TYPE), "{0}", strings.Join(ff.mimeTypes, ", "), -1)
+        } else {
+            vr.IsValid = true
+        }
+    } else {
+        vr.IsValid = true
+    }
+    if vr.IsValid && ff.file != nil {
+        vr.CleanValue = ff.file
+    }
+    ff.isValid = vr.IsValid
+    ff.cleanValue = vr.CleanValue
+    ff.errorMsg = vr.ErrorMsg
+    return vr
+}
+
+// detect the mime type by the first 512 bytes of the file
+func detectMimeType(fh *multipart.FileHeader) (string, error) {
+    f, err := fh.Open()
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    buf := make([]byte, 512)
+    n, err := f.Read(buf)
+    if err != nil && n == 0 {
+        return "", err
+    }
+    mimeType := http.DetectContentType(buf[:n])
+    if i := strings.Index(mimeType, ";"); i > 0 {
+        mimeType = mimeType[:i]
+    }
+    return mimeType, nil
+}
+
+func matchMimeType(mimeType string, allowed []string) bool {
+    for _, t := range allowed {
+        if t == mimeType || t == "*/*" {
+            return true
+        }
+        if strings.HasSuffix(t, "/*") && strings.HasPrefix(mimeType, t[:len(t)-1]) {
+            return true
+        }
+    }
+    return false
+}
@@ -75,8 +75,22 @@ func (fm *Form) FillByMap(m map[string]string) {
     }
 }
 
+// FillByRequest fills the form by the request values & files,
+// the parsed multipart form is used if the request is parsed.
+// in the goku actions use ctx.FillForm instead,
+// it parses the multipart form by ServerConfig.MultipartMemory
 func (fm *Form) FillByRequest(req *http.Request) {
     for k, f := range fm.Fields {
+        if ff, ok := f.(*FileField); ok {
+            if req.MultipartForm != nil {
+                if fhs := req.MultipartForm.File[k]; len(fhs) > 0 {
+                    ff.SetFile(fhs[0])
+                }
+            } else if _, fh, err := req.FormFile(k); err == nil {
+                ff.SetFile(fh)
+            }
+            continue
+        }
         f.SetValue(req.FormValue(k))
     }
 }
@@ -1,6 +1,10 @@
 package form
 
 import (
+    "bytes"
+    "mime/multipart"
+    "net/http"
+    "strings"
     "testing"
     //"fmt"
     "github.com/couchbaselabs/go.assert"
@@ -99,3 +103,30 @@ func TestForm(t *testing.T) {
     assert.Equals(t, sv["name"], "lu")
     // fmt.Printf("%s\n", form.Errors())
 }
+
+func TestFileField(t *testing.T) {
+    body := new(bytes.Buffer)
+    mw := multipart.NewWriter(body)
+    fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+    fw.Write([]byte("\x89PNG\x0D\x0A\x1A\x0A" + strings.Repeat("\x00", 20)))
+    mw.Close()
+    req, _ := http.NewRequest("POST", "/", body)
+    req.Header.Set("Content-Type", mw.FormDataContentType())
+
+    avatar := NewFileField("avatar", "Avatar", true).MaxSize(1024).MimeTypes("image/*")
+    form := NewForm(avatar)
+    form.FillByRequest(req)
+    assert.Equals(t, form.Valid(), true)
+    assert.Equals(t, avatar.File().Filename, "avatar.png")
+
+    avatar.MimeTypes("image/jpeg")
+    assert.Equals(t, form.Valid(), false)
+    assert.Equals(t, avatar.ErrorMsg(), "file type must be one of image/jpeg")
+
+    avatar.MimeTypes().MaxSize(10)
+    assert.Equals(t, form.Valid(), false)
+
+    avatar.SetFile(nil)
+    assert.Equals(t, form.Valid(), false)
+    assert.Equals(t, avatar.ErrorMsg(), MSG_REQUIRED)
+}
@@ -0,0 +1,267 @@
+package goku
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// the timeout of a health check if HealthCheck.Timeout is 0
+const DEFAULT_HEALTH_CHECK_TIMEOUT = 5 * time.Second
+
+// HealthCheck is a named check of a dependency, e.g. the database
+type HealthCheck struct {
+    Name string
+    // returns nil if healthy, it should return when c is done
+    Check func(c context.Context) error
+    // DEFAULT_HEALTH_CHECK_TIMEOUT if 0
+    Timeout time.Duration
+    // cache the result for this duration, not cache if 0
+    CacheTTL time.Duration
+    // run the check in /healthz as well, otherwise only /readyz.
+    // set it only if the app can not recover without a restart
+    Liveness bool
+
+    mu        sync.Mutex
+    lastCheck time.Time
+    lastErr   error
+    running   *healthCheckCall
+}
+
+// the running check, the concurrent probes wait for its result
+type healthCheckCall struct {
+    done chan struct{}
+    err  error
+}
+
+func (hc *HealthCheck) run(c context.Context) (err error, cached bool) {
+    hc.mu.Lock()
+    if hc.CacheTTL > 0 && !hc.lastCheck.IsZero() && time.Since(hc.lastCheck) < hc.CacheTTL {
+        err = hc.lastErr
+        hc.mu.Unlock()
+        return err, true
+    }
+    call := hc.running
+    if call == nil {
+        call = &healthCheckCall{done: make(chan struct{})}
+        hc.running = call
+        // not canceled with the probe started it, the others wait for it
+        go hc.check(context.WithoutCancel(c), call)
+    }
+    hc.mu.Unlock()
+    select {
+    case <-call.done:
+        return call.err, false
+    case <-c.Done():
+        return c.Err(), false
+    }
+}
+
+func (hc *HealthCheck) check(c context.Context, call *healthCheckCall) {
+    timeout := hc.Timeout
+    if timeout <= 0 {
+        timeout = DEFAULT_HEALTH_CHECK_TIMEOUT
+    }
+    c, cancel := context.WithTimeout(c, timeout)
+    defer cancel()
+    done := make(chan error, 1)
+    go func() {
+        defer func() {
+            if e := recover(); e != nil {
+                done <- fmt.Errorf("panic: %v", e)
+            }
+        }()
+        done <- hc.Check(c)
+    }()
+    var err error
+    select {
+    case err = <-done:
+    case <-c.Done():
+        err = errors.New("timeout after " + timeout.String())
+    }
+    call.err = err
+    hc.mu.Lock()
+    hc.running = nil
+    // canceled is not the state of the dependency
+    if !errors.Is(err, context.Canceled) {
+        hc.lastCheck, hc.lastErr = time.Now(), err
+    }
+    hc.mu.Unlock()
+    close(call.done)
+}
+
+// the result of a check
+type HealthCheckResult struct {
+    Status     string  `json:"status"` // "ok" or "fail"
+    Error      string  `json:"error,omitempty"`
+    DurationMs float64 `json:"duration_ms"`
+    Cached     bool    `json:"cached,omitempty"`
+}
+
+// HealthReport is the json body of /healthz and /readyz
+type HealthReport struct {
+    Status string                        `json:"status"` // "ok" or "fail"
+    Reason string                        `json:"reason,omitempty"`
+    Checks map[string]*HealthCheckResult `json:"checks"`
+}
+
+// OK returns whether all the checks passed
+func (r *HealthReport) OK() bool {
+    return r.Status == "ok"
+}
+
+// HealthRegistry holds the health checks,
+// and the state of the graceful shutdown
+type HealthRegistry struct {
+    mu           sync.RWMutex
+    checks       map[string]*HealthCheck
+    shuttingDown int32
+}
+
+func CreateHealthRegistry() *HealthRegistry {
+    return &HealthRegistry{checks: make(map[string]*HealthCheck)}
+}
+
+// the registry used by ServerConfig.HealthPath & ReadyPath
+var DefaultHealthRegistry = CreateHealthRegistry()
+
+// Register adds a check, replace the check with the same name
+func (hr *HealthRegistry) Register(check *HealthCheck) {
+    if check.Name == "" || check.Check == nil {
+        panic("HealthRegistry: Name and Check of the HealthCheck must be set")
+    }
+    hr.mu.Lock()
+    hr.checks[check.Name] = check
+    hr.mu.Unlock()
+}
+
+// Unregister removes the check by name
+func (hr *HealthRegistry) Unregister(name string) {
+    hr.mu.Lock()
+    delete(hr.checks, name)
+    hr.mu.Unlock()
+}
+
+// RegisterHealthCheck adds a check to the DefaultHealthRegistry, e.g.
+//      goku.RegisterHealthCheck(&goku.HealthCheck{
+//          Name:     "mysql",
+//          Check:    goku.DBPingCheck(&mysqlDB.DB),
+//          Timeout:  time.Second,
+//          CacheTTL: 5 * time.Second,
+//      })
+func RegisterHealthCheck(check *HealthCheck) {
+    DefaultHealthRegistry.Register(check)
+}
+
+// DBPingCheck checks the db by ping
+func DBPingCheck(db *DB) func(c context.Context) error {
+    return func(c context.Context) error {
+        return db.PingContext(c)
+    }
+}
+
+// SetShuttingDown marks the server is shutting down,
+// the readiness fails after this. Server.Shutdown calls it
+func (hr *HealthRegistry) SetShuttingDown(shuttingDown bool) {
+    var v int32
+    if shuttingDown {
+        v = 1
+    }
+    atomic.StoreInt32(&hr.shuttingDown, v)
+}
+
+func (hr *HealthRegistry) IsShuttingDown() bool {
+    return atomic.LoadInt32(&hr.shuttingDown) == 1
+}
+
+// Run runs the checks at the same time,
+// all the checks for readiness, only the Liveness checks if not
+func (hr *HealthRegistry) Run(c context.Context, readiness bool) *HealthReport {
+    hr.mu.RLock()
+    checks := make([]*HealthCheck, 0, len(hr.checks))
+    for _, check := range hr.checks {
+        if readiness || check.Liveness {
+            checks = append(checks, check)
+        }
+    }
+    hr.mu.RUnlock()
+    sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+
+    report := &HealthReport{
+        Status: "ok",
+        Checks: make(map[string]*HealthCheckResult, len(checks)),
+    }
+    results := make([]*HealthCheckResult, len(checks))
+    var wg sync.WaitGroup
+    for i, check := range checks {
+        wg.Add(1)
+        go func(i int, check *HealthCheck) {
+            defer wg.Done()
+            start := time.Now()
+            err, cached := check.run(c)
+            r := &HealthCheckResult{
+                Status:     "ok",
+                DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+                Cached:     cached,
+            }
+            if err != nil {
+                r.Status, r.Error = "fail", err.Error()
+            }
+            results[i] = r
+        }(i, check)
+    }
+    wg.Wait()
+    for i, check := range checks {
+        report.Checks[check.Name] = results[i]
+        if results[i].Status != "ok" {
+            report.Status = "fail"
+        }
+    }
+    if readiness && hr.IsShuttingDown() {
+        report.Status, report.Reason = "fail", "shutting down"
+    }
+    return report
+}
+
+// Handler gets the http.Handler of /healthz, or /readyz if readiness.
+// responds 200 if ok, 503 if not, with the HealthReport json
+func (hr *HealthRegistry) Handler(readiness bool) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        report := hr.Run(r.Context(), readiness)
+        b, _ := json.Marshal(report)
+        w.Header().Set("Content-Type", "application/json; charset=utf-8")
+        w.Header().Set("Cache-Control", "no-store")
+        if report.OK() {
+            w.WriteHeader(http.StatusOK)
+        } else {
+            w.WriteHeader(http.StatusServiceUnavailable)
+        }
+        w.Write(b)
+    })
+}
+
+// Shutdown gracefully shuts down the server, see http.Server.Shutdown.
+// the readiness fails first, and wait ServerConfig.ShutdownDelay
+// for the load balancer to stop sending the requests.
+// the server is shut down even if c is done in the delay
+func (s *Server) Shutdown(c context.Context) error {
+    DefaultHealthRegistry.SetShuttingDown(true)
+    rh, _ := s.Handler.(*RequestHandler)
+    // the event streams never end by themselves
+    if rh != nil && rh.liveReloader != nil {
+        rh.liveReloader.Stop()
+    }
+    if rh != nil && rh.ServerConfig.ShutdownDelay > 0 {
+        select {
+        case <-time.After(rh.ServerConfig.ShutdownDelay):
+        case <-c.Done():
+        }
+    }
+    return s.Server.Shutdown(c)
+}
@@ -0,0 +1,175 @@
+package goku
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestHealthRegistry(t *testing.T) {
+    hr := CreateHealthRegistry()
+    calls := 0
+    hr.Register(&HealthCheck{
+        Name: "cache",
+        Check: func(c context.Context) error {
+            calls++
+            return nil
+        },
+        CacheTTL: time.Minute,
+        Liveness: true,
+    })
+    hr.Register(&HealthCheck{
+        Name: "db",
+        Check: func(c context.Context) error {
+            return errors.New("connection refused")
+        },
+    })
+    hr.Register(&HealthCheck{
+        Name: "slow",
+        Check: func(c context.Context) error {
+            time.Sleep(time.Second)
+            return nil
+        },
+        Timeout: 10 * time.Millisecond,
+    })
+
+    // liveness only runs the Liveness checks
+    report := hr.Run(context.Background(), false)
+    assert.True(t, report.OK())
+    assert.Equals(t, len(report.Checks), 1)
+    assert.Equals(t, report.Checks["cache"].Cached, false)
+
+    report = hr.Run(context.Background(), true)
+    assert.True(t, !report.OK())
+    assert.Equals(t, len(report.Checks), 3)
+    assert.Equals(t, report.Checks["cache"].Status, "ok")
+    assert.Equals(t, report.Checks["cache"].Cached, true)
+    assert.Equals(t, calls, 1)
+    assert.Equals(t, report.Checks["db"].Error, "connection refused")
+    assert.Equals(t, report.Checks["slow"].Status, "fail")
+    assert.Equals(t, report.Checks["slow"].Error, "timeout after 10ms")
+
+    hr.Unregister("db")
+    hr.Unregister("slow")
+    assert.True(t, hr.Run(context.Background(), true).OK())
+    hr.SetShuttingDown(true)
+    report = hr.Run(context.Background(), true)
+    assert.True(t, !report.OK())
+    assert.Equals(t, report.Reason, "shutting down")
+    // liveness is not affected
+    assert.True(t, hr.Run(context.Background(), false).OK())
+}
+
+func TestHealthCheckConcurrent(t *testing.T) {
+    var calls int32
+    hc := &HealthCheck{
+        Name: "slow",
+        Check: func(c context.Context) error {
+            atomic.AddInt32(&calls, 1)
+            time.Sleep(50 * time.Millisecond)
+            return nil
+        },
+    }
+    // the concurrent probes share the running check
+    var wg sync.WaitGroup
+    for i := 0; i < 5; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            err, _ := hc.run(context.Background())
+            assert.Equals(t, err, nil)
+        }()
+    }
+    wg.Wait()
+    assert.Equals(t, atomic.LoadInt32(&calls), int32(1))
+
+    // the probe canceled by the client gets the error, it is not cached
+    c, cancel := context.WithCancel(context.Background())
+    cancel()
+    err, _ := hc.run(c)
+    assert.Equals(t, err, context.Canceled)
+    err, _ = hc.run(context.Background())
+    assert.Equals(t, err, nil)
+
+    var canceledCalls int32
+    hc = &HealthCheck{
+        Name: "canceled",
+        Check: func(c context.Context) error {
+            if atomic.AddInt32(&canceledCalls, 1) == 1 {
+                return context.Canceled
+            }
+            return nil
+        },
+        CacheTTL: time.Minute,
+    }
+    err, _ = hc.run(context.Background())
+    assert.Equals(t, err, context.Canceled)
+    err, cached := hc.run(context.Background())
+    assert.Equals(t, err, nil)
+    assert.Equals(t, cached, false)
+}
+
+func TestShutdownDelayCanceled(t *testing.T) {
+    defer DefaultHealthRegistry.SetShuttingDown(false)
+    l, _ := net.Listen("tcp", "127.0.0.1:0")
+    s := &Server{}
+    s.Handler = createTestHandler(&ServerConfig{ShutdownDelay: time.Minute})
+    served := make(chan error, 1)
+    go func() {
+        served <- s.Serve(l)
+    }()
+
+    // the server is shut down even if the delay is not over
+    c, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    s.Shutdown(c)
+    select {
+    case err := <-served:
+        assert.Equals(t, err, http.ErrServerClosed)
+    case <-time.After(time.Second):
+        t.Fatal("the server is not shut down")
+    }
+}
+
+func TestHealthPath(t *testing.T) {
+    rh := createTestHandler(&ServerConfig{HealthPath: "/healthz", ReadyPath: "/readyz"})
+    RegisterHealthCheck(&HealthCheck{
+        Name: "healthtest",
+        Check: func(c context.Context) error {
+            return nil
+        },
+    })
+    defer DefaultHealthRegistry.Unregister("healthtest")
+
+    w := httptest.NewRecorder()
+    req, _ := http.NewRequest("GET", "/readyz", nil)
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.Equals(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+    var report HealthReport
+    json.Unmarshal(w.Body.Bytes(), &report)
+    assert.Equals(t, report.Status, "ok")
+    assert.Equals(t, report.Checks["healthtest"].Status, "ok")
+
+    // readiness fails when shutting down
+    s := &Server{}
+    s.Handler = rh
+    s.Shutdown(context.Background())
+    defer DefaultHealthRegistry.SetShuttingDown(false)
+    w = httptest.NewRecorder()
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusServiceUnavailable)
+
+    w = httptest.NewRecorder()
+    req, _ = http.NewRequest("GET", "/healthz", nil)
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusOK)
+}
@@ -2,10 +2,14 @@ package goku
 
 import (
     "bytes"
+    "context"
     "encoding/json"
     "fmt"
+    "github.com/QLeelulu/goku/form"
     "net/http"
     "path"
+    "strings"
+    "time"
 )
 
 // http context
@@ -27,6 +31,14 @@ type HttpContext struct {
     requestHandler       *RequestHandler
     responseContentCache *bytes.Buffer // cache response content, will write at end request
     responseStatusCode   int           // cache response status code, will write at end request
+    requestId            string        // unique id of the request
+    startTime            time.Time     // the time the request started
+    responseSize         int           // bytes of the response body written
+    logger               StructLogger  // logger of the request
+    loggerHasRoute       bool          // whether the logger has the route fields
+    span                 *Span         // the root span of the request, nil if the tracing is disabled
+    multipartErr         error         // the error of parsing the multipart form
+    actionMatched        bool          // whether the action of the route is found
     //responseHeaderCache  Header        // cache response header, will write at end request
 }
 
@@ -40,10 +52,25 @@ func (ctx *HttpContext) flushToResponse() {
         ctx.responseWriter.WriteHeader(ctx.responseStatusCode)
     }
     if ctx.responseContentCache.Len() > 0 {
+        ctx.responseSize += ctx.responseContentCache.Len()
         ctx.responseContentCache.WriteTo(ctx.responseWriter)
     }
 }
 
+// Context gets the context of the request,
+// it is canceled when the client disconnects,
+// or the route's Timeout exceeded.
+// pass it to the db calls, e.g. db.SelectContext(ctx.Context(), ...)
+func (ctx *HttpContext) Context() context.Context {
+    return ctx.Request.Context()
+}
+
+// WithValue adds a value to the context of the request,
+// get it by ctx.Context().Value(key)
+func (ctx *HttpContext) WithValue(key, val interface{}) {
+    ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), key, val))
+}
+
 // Try not to use this unless you know exactly what you are doing
 func (ctx *HttpContext) ResponseWriter() http.ResponseWriter {
     return ctx.responseWriter
@@ -63,15 +90,92 @@ func (ctx *HttpContext) ViewPath() string {
 
 // get the requert param, 
 // get from RouteData first, 
-// if no, get from Requet.FormValue
+// if no, get from Requet.FormValue.
+// the error of parsing the multipart form is logged once, get it by ctx.FormError
 func (ctx *HttpContext) Get(name string) string {
     v, ok := ctx.RouteData.Get(name)
     if ok {
         return v
     }
+    ctx.parseMultipartForm()
     return ctx.Request.FormValue(name)
 }
 
+// FormError gets the error of parsing the multipart form, nil if parsed or not multipart,
+// it is a 413 *HTTPError if the body is larger than MaxBodyBytes:
+//      title := ctx.Get("title")
+//      if err := ctx.FormError(); err != nil {
+//          return nil, err
+//      }
+func (ctx *HttpContext) FormError() error {
+    if err := ctx.parseMultipartForm(); err != http.ErrNotMultipart {
+        return err
+    }
+    return nil
+}
+
+// FillForm fills the form by the request, like form.FillByRequest,
+// the multipart form is parsed by ServerConfig.MultipartMemory
+func (ctx *HttpContext) FillForm(f *form.Form) error {
+    if err := ctx.FormError(); err != nil {
+        return err
+    }
+    f.FillByRequest(ctx.Request)
+    return nil
+}
+
+// parse the multipart form if the request is multipart,
+// the file parts larger than ServerConfig.MultipartMemory
+// will be stored in temp files.
+// the body larger than MaxBodyBytes is a 413 *HTTPError
+func (ctx *HttpContext) parseMultipartForm() error {
+    if ctx.Request.MultipartForm != nil || ctx.multipartErr != nil {
+        return ctx.multipartErr
+    }
+    if !strings.HasPrefix(ctx.GetHeader("Content-Type"), "multipart/form-data") {
+        return http.ErrNotMultipart
+    }
+    maxMemory := ctx.requestHandler.ServerConfig.MultipartMemory
+    if maxMemory <= 0 {
+        maxMemory = 32 << 20
+    }
+    if err := ctx.Request.ParseMultipartForm(maxMemory); err != nil {
+        if isBodyTooLarge(err) {
+            err = NewHTTPError(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge)).WithCause(err)
+        }
+        ctx.multipartErr = err
+        ctx.Logger().Warn("parse multipart form error", "error", err)
+    }
+    return ctx.multipartErr
+}
+
+// FormFile gets the first uploaded file for the form field name.
+// returns http.ErrMissingFile if no such file
+func (ctx *HttpContext) FormFile(name string) (*UploadFile, error) {
+    files, err := ctx.FormFiles(name)
+    if err != nil {
+        return nil, err
+    }
+    return files[0], nil
+}
+
+// FormFiles gets all the uploaded files for the form field name.
+// returns http.ErrMissingFile if no such file
+func (ctx *HttpContext) FormFiles(name string) ([]*UploadFile, error) {
+    if err := ctx.parseMultipartForm(); err != nil {
+        return nil, err
+    }
+    fhs := ctx.Request.MultipartForm.File[name]
+    if len(fhs) == 0 {
+        return nil, http.ErrMissingFile
+    }
+    files := make([]*UploadFile, 0, len(fhs))
+    for _, fh := range fhs {
+        files = append(files, &UploadFile{FieldName: name, FileHeader: fh})
+    }
+    return files, nil
+}
+
 // Header gets the response header
 func (ctx *HttpContext) Header() http.Header {
     return ctx.responseWriter.Header()
@@ -199,16 +303,13 @@ func (ctx *HttpContext) RedirectPermanent(url_ string) ActionResulter {
     }
 }
 
-// page not found
+// page not found,
+// the response is made by ServerConfig.ErrorHandlers if set
 func (ctx *HttpContext) NotFound(message string) ActionResulter {
     if message == "" {
         message = "Page Not Found!"
     }
-    return &ActionResult{
-        StatusCode: http.StatusNotFound,
-        Headers:    map[string]string{"Content-Type": "text/html"},
-        Body:       bytes.NewBufferString(message),
-    }
+    return createErrorResult(http.StatusNotFound, message)
 }
 
 // content not modified
@@ -218,14 +319,38 @@ func (ctx *HttpContext) NotModified() ActionResulter {
     }
 }
 
-func (ctx *HttpContext) Error(err interface{}) ActionResulter {
-    msg := fmt.Sprintf("%v", err)
-    return &ActionResult{
-        StatusCode: http.StatusInternalServerError,
-        Headers:    map[string]string{"Content-Type": "text/plain"},
-        Body:       bytes.NewBufferString(msg),
+// LastModified sets the Last-Modified header,
+// and returns true if the client's copy is still fresh,
+// so the action can short-circuit before rendering:
+//      if ctx.LastModified(blog.UpdateAt) {
+//          return ctx.NotModified()
+//      }
+func (ctx *HttpContext) LastModified(t time.Time) bool {
+    if t.IsZero() {
+        return false
+    }
+    ctx.SetHeader("Last-Modified", t.UTC().Format(http.TimeFormat))
+    if ctx.Method != "GET" && ctx.Method != "HEAD" {
+        return false
     }
+    // the ETag is computed after rendering,
+    // so can't use If-None-Match here
+    if ctx.GetHeader("If-None-Match") != "" {
+        return false
+    }
+    return isNotModified(ctx, "")
+}
 
+// internal server error, or the status code of an HTTPError,
+// the response is made by ServerConfig.ErrorHandlers if set.
+// like the error returned from the action, only the message of an HTTPError
+// is sent to the client, the others are shown in debug mode only
+func (ctx *HttpContext) Error(err interface{}) ActionResulter {
+    e, ok := err.(error)
+    if !ok {
+        e = fmt.Errorf("%v", err)
+    }
+    return errorResultOf(e)
 }
 
 func (ctx *HttpContext) Raw(data string) ActionResulter {
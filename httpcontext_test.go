@@ -0,0 +1,38 @@
+package goku
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+type testContextKey string
+
+func TestHttpContextWithValue(t *testing.T) {
+    ctx, _ := createTestContext("GET", "/", nil)
+    ctx.WithValue(testContextKey("user"), "lulu")
+    assert.Equals(t, ctx.Context().Value(testContextKey("user")), "lulu")
+    // request id is in the context too
+    assert.Equals(t, RequestIdFromContext(ctx.Context()), ctx.RequestID())
+}
+
+func TestRouteTimeout(t *testing.T) {
+    Controller("timeouttest").
+        Get("slow", func(ctx *HttpContext) ActionResulter {
+        select {
+        case <-ctx.Context().Done():
+        case <-time.After(time.Second):
+        }
+        return ctx.Raw("done")
+    })
+    rh := createTestHandler(&ServerConfig{})
+    rh.RouteTable.Routes[0].Timeout = 10 * time.Millisecond
+
+    w := httptest.NewRecorder()
+    start := time.Now()
+    rh.ServeHTTP(w, httptest.NewRequest("GET", "/timeouttest/slow", nil))
+    assert.Equals(t, time.Since(start) < 500*time.Millisecond, true)
+    assert.Equals(t, w.Code, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,129 @@
+package goku
+
+import (
+    "context"
+    "errors"
+    "net/http"
+)
+
+// HTTPError is an error with the http status code,
+// return it from the actions, filters or middlewares:
+//      Controller("blog").
+//          GetE("show", func(ctx *goku.HttpContext) (goku.ActionResulter, error) {
+//              blog, err := getBlog(ctx.Get("id"))
+//              if err == sql.ErrNoRows {
+//                  return nil, goku.NotFound("no such blog")
+//              } else if err != nil {
+//                  return nil, goku.InternalServerError(err)
+//              }
+//              return ctx.View(blog), nil
+//          })
+// only Message and Details are sent to the client, Cause is for the logs.
+// it is an ActionResulter too, so the actions without error can return it
+type HTTPError struct {
+    StatusCode int
+    Message    string      // the public message, the status text if empty
+    Cause      error       // the internal error, not sent to the client
+    Details    interface{} // sent to the client with Message, e.g. the invalid fields
+}
+
+// NewHTTPError creates an HTTPError, the message is the status text if empty
+func NewHTTPError(statusCode int, message string) *HTTPError {
+    if message == "" {
+        message = http.StatusText(statusCode)
+    }
+    return &HTTPError{StatusCode: statusCode, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+    if e.Cause != nil {
+        return e.Message + ": " + e.Cause.Error()
+    }
+    return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+    return e.Cause
+}
+
+// WithCause sets the internal error, returns e
+func (e *HTTPError) WithCause(cause error) *HTTPError {
+    e.Cause = cause
+    return e
+}
+
+// WithDetails sets the details sent to the client, returns e
+func (e *HTTPError) WithDetails(details interface{}) *HTTPError {
+    e.Details = details
+    return e
+}
+
+func (e *HTTPError) ExecuteResult(ctx *HttpContext) {
+    errorResultOf(e).ExecuteResult(ctx)
+}
+
+func BadRequest(message string) *HTTPError {
+    return NewHTTPError(http.StatusBadRequest, message)
+}
+
+func Unauthorized(message string) *HTTPError {
+    return NewHTTPError(http.StatusUnauthorized, message)
+}
+
+func Forbidden(message string) *HTTPError {
+    return NewHTTPError(http.StatusForbidden, message)
+}
+
+func NotFound(message string) *HTTPError {
+    return NewHTTPError(http.StatusNotFound, message)
+}
+
+func MethodNotAllowed(message string) *HTTPError {
+    return NewHTTPError(http.StatusMethodNotAllowed, message)
+}
+
+func Conflict(message string) *HTTPError {
+    return NewHTTPError(http.StatusConflict, message)
+}
+
+func UnprocessableEntity(message string) *HTTPError {
+    return NewHTTPError(http.StatusUnprocessableEntity, message)
+}
+
+func TooManyRequests(message string) *HTTPError {
+    return NewHTTPError(http.StatusTooManyRequests, message)
+}
+
+// InternalServerError wraps the internal error,
+// the client gets "Internal Server Error" only
+func InternalServerError(cause error) *HTTPError {
+    return NewHTTPError(http.StatusInternalServerError, "").WithCause(cause)
+}
+
+func ServiceUnavailable(message string) *HTTPError {
+    return NewHTTPError(http.StatusServiceUnavailable, message)
+}
+
+// map the error to the status code:
+//      *HTTPError                 its StatusCode
+//      context.DeadlineExceeded   503
+//      request body too large     413
+//      others                     500
+// only the message of HTTPError is sent to the client
+func errorResultOf(err error) *ErrorResult {
+    var he *HTTPError
+    var er *ErrorResult
+    switch {
+    case errors.As(err, &he):
+        er = createErrorResult(he.StatusCode, he.Message)
+        er.Details = he.Details
+    case errors.Is(err, context.DeadlineExceeded):
+        er = createErrorResult(http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+    case isBodyTooLarge(err):
+        er = createErrorResult(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+    default:
+        er = createErrorResult(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+    }
+    er.Err = err
+    return er
+}
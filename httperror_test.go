@@ -0,0 +1,116 @@
+package goku
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+type httpErrorTestFilter struct {
+    BaseFilter
+}
+
+func (f *httpErrorTestFilter) OnActionExecuting(ctx *HttpContext) (ActionResulter, error) {
+    if ctx.Get("token") != "secret" {
+        return nil, Forbidden("token required")
+    }
+    return nil, nil
+}
+
+func TestHTTPError(t *testing.T) {
+    Controller("httperrortest").
+        GetE("validate", func(ctx *HttpContext) (ActionResulter, error) {
+        if ctx.Get("name") == "" {
+            return nil, BadRequest("invalid input").WithDetails(map[string]string{"name": "required"})
+        }
+        return ctx.Raw("hello " + ctx.Get("name")), nil
+    }).
+        GetE("internal", func(ctx *HttpContext) (ActionResulter, error) {
+        return nil, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+    }).
+        GetE("wrapped", func(ctx *HttpContext) (ActionResulter, error) {
+        return nil, fmt.Errorf("load blog: %w", NotFound("no such blog").WithCause(errors.New("sql: no rows")))
+    }).
+        GetE("timeout", func(ctx *HttpContext) (ActionResulter, error) {
+        return nil, fmt.Errorf("query: %w", context.DeadlineExceeded)
+    }).
+        Get("result", func(ctx *HttpContext) ActionResulter {
+        return Conflict("")
+    }).
+        Get("secure", func(ctx *HttpContext) ActionResulter {
+        return ctx.Raw("ok")
+    }).Filters(&httpErrorTestFilter{})
+    rh := createTestHandler(&ServerConfig{})
+
+    get := func(url, accept string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", url, nil)
+        req.Header.Set("Accept", accept)
+        rh.ServeHTTP(w, req)
+        return w
+    }
+
+    w := get("/httperrortest/validate?name=goku", "")
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.Equals(t, w.Body.String(), "hello goku")
+
+    w = get("/httperrortest/validate", "application/json")
+    assert.Equals(t, w.Code, http.StatusBadRequest)
+    assert.True(t, strings.Contains(w.Body.String(), `"message":"invalid input"`))
+    assert.True(t, strings.Contains(w.Body.String(), `"details":{"name":"required"}`))
+
+    w = get("/httperrortest/validate", "text/html")
+    assert.Equals(t, w.Code, http.StatusBadRequest)
+    assert.Equals(t, w.Body.String(), "invalid input")
+
+    // the internal error is not sent to the client
+    w = get("/httperrortest/internal", "")
+    assert.Equals(t, w.Code, http.StatusInternalServerError)
+    assert.Equals(t, w.Body.String(), "Internal Server Error")
+
+    w = get("/httperrortest/wrapped", "")
+    assert.Equals(t, w.Code, http.StatusNotFound)
+    assert.Equals(t, w.Body.String(), "no such blog")
+
+    w = get("/httperrortest/timeout", "")
+    assert.Equals(t, w.Code, http.StatusServiceUnavailable)
+
+    // HTTPError as the ActionResulter
+    w = get("/httperrortest/result", "")
+    assert.Equals(t, w.Code, http.StatusConflict)
+    assert.Equals(t, w.Body.String(), "Conflict")
+
+    // from the filter
+    w = get("/httperrortest/secure", "")
+    assert.Equals(t, w.Code, http.StatusForbidden)
+    assert.Equals(t, w.Body.String(), "token required")
+    w = get("/httperrortest/secure?token=secret", "")
+    assert.Equals(t, w.Code, http.StatusOK)
+}
+
+func TestHTTPErrorUnwrap(t *testing.T) {
+    cause := errors.New("sql: no rows")
+    err := InternalServerError(cause)
+    assert.Equals(t, err.StatusCode, http.StatusInternalServerError)
+    assert.Equals(t, err.Message, "Internal Server Error")
+    assert.Equals(t, err.Error(), "Internal Server Error: sql: no rows")
+    assert.True(t, errors.Is(err, cause))
+
+    ctx, _ := createTestContext("GET", "/", nil)
+    ar := ctx.Error(Unauthorized("login required"))
+    assert.Equals(t, ar.(*ErrorResult).StatusCode, http.StatusUnauthorized)
+}
+
+func TestRegActionE(t *testing.T) {
+    ai := Controller("httperrortest2").GetE("index", func(ctx *HttpContext) (ActionResulter, error) {
+        return nil, nil
+    }).currentAction
+    assert.True(t, ai.Handler == nil)
+    assert.True(t, ai.HandlerWithError != nil)
+    assert.True(t, defaultControllerFactory.GetAction("GET", "httperrortest2", "index") == ai)
+}
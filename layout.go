@@ -0,0 +1,258 @@
+package goku
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "html/template"
+    "io"
+    "os"
+    "path"
+    "regexp"
+    "strings"
+)
+
+// the max depth of the nested layouts, to stop the circular layouts
+const MAX_LAYOUT_DEPTH = 10
+
+// the max depth of the nested partials, to stop the partials include themselves
+const MAX_PARTIAL_DEPTH = 20
+
+// NestedLayoutViewEnginer is implemented by the view engines
+// which support the layouts have parent layouts, e.g. DefaultViewEngine
+type NestedLayoutViewEnginer interface {
+    // find the view and its layouts, the nearest layout first
+    FindLayouts(vi *ViewInfo) (viewPath string, layoutPaths []string, err error)
+}
+
+// NestedLayoutTemplateEnginer is implemented by the template engines
+// which can render the view in the nested layouts, e.g. DefaultTemplateEngine
+type NestedLayoutTemplateEnginer interface {
+    // render the view in the layouts, the nearest layout first,
+    // nothing is written to w if returns an error
+    RenderLayouts(viewPath string, layoutPaths []string, viewData *ViewData, w io.Writer) error
+}
+
+// the layout directive at the beginning of the view or the layout:
+//      {{/* layout: admin */}}
+// the value "none" means no layout
+var regLayoutDirective = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*layout:?\s*"?([\w./\-]+)"?\s*\*/\s*-?\}\}`)
+
+// read the layout directive of the file, ok is false if not set
+func readLayoutDirective(file string) (layout string, ok bool) {
+    f, err := os.Open(file)
+    if err != nil {
+        return "", false
+    }
+    defer f.Close()
+    b := make([]byte, 256)
+    n, _ := io.ReadFull(f, b)
+    m := regLayoutDirective.FindSubmatch(b[:n])
+    if m == nil {
+        return "", false
+    }
+    return string(m[1]), true
+}
+
+// get the layout directive of the file, cached if UseCache
+func (ve *DefaultViewEngine) layoutDirective(file string) (string, bool) {
+    if ve.UseCache {
+        ve.mu.RLock()
+        d, ok := ve.directives[file]
+        ve.mu.RUnlock()
+        if ok {
+            return d.layout, d.ok
+        }
+    }
+    layout, ok := readLayoutDirective(file)
+    if ve.UseCache {
+        ve.mu.Lock()
+        if ve.directives == nil {
+            ve.directives = make(map[string]layoutDirective)
+        }
+        ve.directives[file] = layoutDirective{layout, ok}
+        ve.mu.Unlock()
+    }
+    return layout, ok
+}
+
+type layoutDirective struct {
+    layout string
+    ok     bool
+}
+
+// FindLayouts finds the view, its layout, and the parent layouts
+// set by the layout directive of the layouts:
+//      views/shared/admin.html:
+//          {{/* layout: layout */}}
+//          <div class="admin">{{.Body}}</div>
+// the nearest layout first
+func (ve *DefaultViewEngine) FindLayouts(vi *ViewInfo) (viewPath string, layoutPaths []string, err error) {
+    viewPath, layoutPath, err := ve.FindView(vi)
+    if err != nil {
+        return "", nil, err
+    }
+    for layoutPath != "" {
+        for _, p := range layoutPaths {
+            if p == layoutPath {
+                return "", nil, fmt.Errorf("DefaultViewEngine: circular layouts %s -> %s", layoutPaths, layoutPath)
+            }
+        }
+        if len(layoutPaths) >= MAX_LAYOUT_DEPTH {
+            return "", nil, fmt.Errorf("DefaultViewEngine: the layouts are nested too deep, %s", layoutPaths)
+        }
+        layoutPaths = append(layoutPaths, layoutPath)
+        parent, ok := ve.layoutDirective(layoutPath)
+        if !ok || parent == "none" {
+            break
+        }
+        layoutPath, _ = ve.lookup(&ViewInfo{Controller: vi.Controller, Layout: parent}, true, path.Ext(viewPath))
+        if layoutPath == "" {
+            return "", nil, fmt.Errorf("DefaultViewEngine: can't find the layout %s of %s", parent, layoutPaths[len(layoutPaths)-1])
+        }
+    }
+    return
+}
+
+// the files to parse, the root layout first, the view last,
+// so the sections of the view override the defaults of the layouts
+func templateFiles(viewPath string, layoutPaths []string) []string {
+    files := make([]string, 0, len(layoutPaths)+1)
+    for i := len(layoutPaths) - 1; i >= 0; i-- {
+        files = append(files, layoutPaths[i])
+    }
+    return append(files, viewPath)
+}
+
+// the cache key of the parsed template files
+func templateCacheKey(filepaths []string) string {
+    return strings.Join(filepaths, "_")
+}
+
+// whether the template files of the cache key use the file
+func templateCacheKeyHas(key, file string) bool {
+    // the key is the files joined by "_"
+    return key == file || strings.HasPrefix(key, file+"_") || strings.HasSuffix(key, "_"+file) ||
+        strings.Contains(key, "_"+file+"_")
+}
+
+// the parsed template files of html/template or text/template, for executeLayouts
+type layoutTemplate struct {
+    execute func(wr io.Writer, name string, data interface{}) error
+    defined func(name string) bool
+    body    func(s string) interface{}         // the rendered content as ViewData.Body
+    funcs   func(funcs map[string]interface{}) // binds the funcs of the render, e.g. section
+}
+
+// executes the view, then the layouts from the nearest one,
+// the output is written to wr only if all rendered
+func executeLayouts(tmpl *layoutTemplate, filepaths []string, viewData *ViewData, wr io.Writer) error {
+    tmpl.funcs(map[string]interface{}{
+        "section": func(name string, data ...interface{}) (template.HTML, error) {
+            if !tmpl.defined(name) {
+                return "", nil
+            }
+            var b bytes.Buffer
+            err := tmpl.execute(&b, name, viewData)
+            return template.HTML(b.String()), err
+        },
+    })
+    // the view is the last, the root layout is the first
+    var b bytes.Buffer
+    for i := len(filepaths) - 1; i >= 0; i-- {
+        b.Reset()
+        if err := tmpl.execute(&b, filepaths[i], viewData); err != nil {
+            return &TemplateError{Files: filepaths, Err: err}
+        }
+        if i > 0 {
+            viewData.Body = tmpl.body(b.String())
+        }
+    }
+    _, err := b.WriteTo(wr)
+    return err
+}
+
+// RenderLayouts renders the view, then the layouts from the nearest one,
+// the rendered content is set to ViewData.Body of the next layout.
+// all the files are parsed together, so the sections
+// defined in the view can be rendered by the layouts.
+// the output is written to wr only if all rendered,
+// returns a *TemplateError if failed
+func (te *DefaultTemplateEngine) RenderLayouts(viewPath string, layoutPaths []string, viewData *ViewData, wr io.Writer) error {
+    filepaths := templateFiles(viewPath, layoutPaths)
+    tmpl, err := te.parse(filepaths)
+    if err != nil {
+        return err
+    }
+    // a clone for each render, the funcs are bound to it
+    if tmpl, err = tmpl.Clone(); err != nil {
+        return &TemplateError{Files: filepaths, Err: err}
+    }
+    return executeLayouts(&layoutTemplate{
+        execute: tmpl.ExecuteTemplate,
+        defined: func(name string) bool { return tmpl.Lookup(name) != nil },
+        body:    func(s string) interface{} { return template.HTML(s) },
+        funcs:   func(funcs map[string]interface{}) { tmpl.Funcs(funcs) },
+    }, filepaths, viewData, wr)
+}
+
+// the state of the rendering view, for the partial func
+type viewRenderer struct {
+    viewEngine     ViewEnginer
+    templateEngine TemplateEnginer
+    controller     string
+}
+
+func init() {
+    builtinTemplateFuncs["section"] = renderSection
+    builtinTemplateFuncs["partial"] = renderPartial
+}
+
+// renders the section defined in the view or the child layout,
+// the layout can define the default content, empty if not defined:
+//      layout: <head>{{section "head"}}</head> ... {{section "scripts"}}
+//              {{define "scripts"}}<script src="/static/site.js"></script>{{end}}
+//      view:   {{define "scripts"}}<script src="/static/blog.js"></script>{{end}}
+// it is replaced by the section func of the render, see executeLayouts,
+// the data argument is ignored, for the templates like {{section "head" .}}
+func renderSection(name string, data ...interface{}) (template.HTML, error) {
+    return "", nil
+}
+
+// renders the partial view found like ctx.RenderPartial,
+// the view name with a dir is relative to the view root.
+// the model of the partial is the same as the current view if not set:
+//      {{partial "shared/_nav" .}}
+//      {{range .Model.Comments}}{{partial "_comment" $ .}}{{end}}
+func renderPartial(name string, viewData *ViewData, model ...interface{}) (html template.HTML, err error) {
+    if viewData == nil || viewData.renderer == nil {
+        return "", errors.New("partial: the data must be the ViewData, e.g. {{partial \"" + name + "\" .}}")
+    }
+    if viewData.partialDepth >= MAX_PARTIAL_DEPTH {
+        return "", fmt.Errorf("partial: the partials are nested too deep, %d levels at %s", MAX_PARTIAL_DEPTH, name)
+    }
+    r := viewData.renderer
+    if strings.Contains(name, "/") && name[0] != '/' {
+        name = "/" + name
+    }
+    pvd := &ViewData{
+        Data:     viewData.Data,
+        Model:    viewData.Model,
+        Globals:  viewData.Globals,
+        renderer: r,
+
+        partialDepth: viewData.partialDepth + 1,
+    }
+    if len(model) > 0 {
+        pvd.Model = model[0]
+    }
+    viewPath, _, err := r.viewEngine.FindView(&ViewInfo{Controller: r.controller, View: name, IsPartial: true})
+    if err != nil {
+        return "", err
+    }
+    var b bytes.Buffer
+    if err = r.templateEngine.Render(viewPath, "", pvd, &b); err != nil {
+        return "", err
+    }
+    return template.HTML(b.String()), nil
+}
@@ -0,0 +1,110 @@
+package goku
+
+import (
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestNestedLayouts(t *testing.T) {
+    dir := createTestViews(t, map[string]string{
+        // the section with or without the data
+        "shared/layout.html": `<html><head>{{section "head"}}</head><body>{{.Body}}` +
+            `{{section "scripts" .}}</body></html>` +
+            `{{define "scripts"}}<script src="site.js"></script>{{end}}`,
+        "shared/admin.html": "{{/* layout: layout */ -}}\n" +
+            `<div class="admin">{{partial "shared/_nav" .}}{{.Body}}</div>`,
+        "shared/_nav.html": `<nav>{{.Globals.SiteName}} {{.Model.Title}}</nav>`,
+        "layouttest/_item.html": `<li>{{.Model}}</li>`,
+        "layouttest/index.html": `{{define "head"}}<title>{{.Model.Title}}</title>{{end}}` +
+            `<h1>{{.Model.Title}}</h1><ul>{{range .Model.Items}}{{partial "_item" $ .}}{{end}}</ul>`,
+        "layouttest/dashboard.html": "{{/* layout: admin */}}\n" +
+            `{{define "scripts"}}<script src="admin.js"></script>{{end}}<p>dashboard</p>`,
+        "layouttest/bare.html":  "{{/* layout: none */}}\n<p>bare</p>",
+        "layouttest/loop.html":  "{{/* layout: loop */}}\n<p>loop</p>",
+        "layouttest/nosuch.html": `{{partial "_nosuch" .}}`,
+        "layouttest/_self.html":  `{{partial "_self" .}}`,
+        "layouttest/self.html":   `{{partial "_self" .}}`,
+    })
+    defer os.RemoveAll(dir)
+    defer setTestGlobalViewData("SiteName", "goku")()
+
+    model := map[string]interface{}{"Title": "Hello", "Items": []string{"a", "b"}}
+    Controller("layouttest").
+        Get("index", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(model)
+    }).
+        Get("dashboard", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(model)
+    }).
+        Get("override", func(ctx *HttpContext) ActionResulter {
+        // the layout of the action take precedence over the view's
+        return ctx.RenderWithLayout("dashboard", "layout", model)
+    }).
+        Get("bare", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(nil)
+    }).
+        Get("loop", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(nil)
+    }).
+        Get("nosuch", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(nil)
+    }).
+        Get("self", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(nil)
+    })
+
+    rh := createTestHandler(&ServerConfig{})
+    rh.TemplateEnginer = CreateDefaultTemplateEngine(true)
+    rh.ViewEnginer = CreateDefaultViewEngine(dir, "", "", true)
+    get := func(url string) (int, string) {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", url, nil)
+        rh.ServeHTTP(w, req)
+        b, _ := ioutil.ReadAll(w.Body)
+        return w.Code, string(b)
+    }
+
+    // the sections of the view, the default sections of the layout
+    code, body := get("/layouttest/index")
+    assert.Equals(t, code, http.StatusOK)
+    assert.Equals(t, body, `<html><head><title>Hello</title></head><body>`+
+        `<h1>Hello</h1><ul><li>a</li><li>b</li></ul>`+
+        `<script src="site.js"></script></body></html>`)
+
+    // the nested layouts, the section of the view in the root layout
+    _, body = get("/layouttest/dashboard")
+    assert.Equals(t, body, `<html><head></head><body>`+
+        "<div class=\"admin\"><nav>goku Hello</nav>\n<p>dashboard</p></div>"+
+        `<script src="admin.js"></script></body></html>`)
+
+    _, body = get("/layouttest/override")
+    assert.Equals(t, body, "<html><head></head><body>\n<p>dashboard</p>"+
+        `<script src="admin.js"></script></body></html>`)
+
+    _, body = get("/layouttest/bare")
+    assert.Equals(t, body, "\n<p>bare</p>")
+
+    code, _ = get("/layouttest/loop")
+    assert.Equals(t, code, http.StatusInternalServerError)
+    code, _ = get("/layouttest/nosuch")
+    assert.Equals(t, code, http.StatusInternalServerError)
+
+    // the partial includes itself
+    code, _ = get("/layouttest/self")
+    assert.Equals(t, code, http.StatusInternalServerError)
+    _, err := renderPartial("_self", &ViewData{renderer: &viewRenderer{
+        viewEngine:     rh.ViewEnginer,
+        templateEngine: rh.TemplateEnginer,
+        controller:     "layouttest",
+    }})
+    assert.True(t, strings.Contains(err.Error(), "partial: the partials are nested too deep"))
+
+    // the same files with the layouts in different order are different templates
+    assert.True(t, strings.Contains(strings.Join(rh.TemplateEnginer.(*DefaultTemplateEngine).CachedTemplates(), ","),
+        "shared/layout.html_"+dir+"/shared/admin.html_"+dir+"/layouttest/dashboard.html"))
+}
@@ -0,0 +1,293 @@
+package goku
+
+import (
+    "bytes"
+    "fmt"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// the path of the live reload events, see ServerConfig.LiveReload
+const LIVE_RELOAD_PATH = "/_goku/livereload"
+
+// the poll interval of FileWatcher if Interval is 0
+const DEFAULT_WATCH_INTERVAL = 500 * time.Millisecond
+
+// FileChange is a changed file found by FileWatcher
+type FileChange struct {
+    Path string
+    Op   string // "create", "write" or "remove"
+}
+
+type fileStamp struct {
+    modTime time.Time
+    size    int64
+}
+
+// FileWatcher polls the dirs for the changed files,
+// it needs no system notify api, so works everywhere, e.g. docker volumes
+type FileWatcher struct {
+    Dirs     []string
+    Interval time.Duration // DEFAULT_WATCH_INTERVAL if 0
+    // called in the watcher goroutine with the changes of a poll
+    OnChange func(changes []FileChange)
+
+    files map[string]fileStamp
+    stop  chan struct{}
+}
+
+func CreateFileWatcher(onChange func(changes []FileChange), dirs ...string) *FileWatcher {
+    return &FileWatcher{
+        Dirs:     dirs,
+        OnChange: onChange,
+    }
+}
+
+// Start takes the snapshot of the dirs and starts polling
+func (fw *FileWatcher) Start() {
+    if fw.stop != nil {
+        return
+    }
+    fw.files = fw.scan()
+    fw.stop = make(chan struct{})
+    interval := fw.Interval
+    if interval <= 0 {
+        interval = DEFAULT_WATCH_INTERVAL
+    }
+    go func(stop chan struct{}) {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                if changes := fw.Poll(); len(changes) > 0 && fw.OnChange != nil {
+                    fw.OnChange(changes)
+                }
+            case <-stop:
+                return
+            }
+        }
+    }(fw.stop)
+}
+
+func (fw *FileWatcher) Stop() {
+    if fw.stop != nil {
+        close(fw.stop)
+        fw.stop = nil
+    }
+}
+
+// Poll compares the dirs with the last snapshot, returns the changes.
+// it is called by the watcher goroutine, call it only if not started
+func (fw *FileWatcher) Poll() []FileChange {
+    files := fw.scan()
+    var changes []FileChange
+    for name, stamp := range files {
+        old, ok := fw.files[name]
+        if !ok {
+            changes = append(changes, FileChange{name, "create"})
+        } else if old != stamp {
+            changes = append(changes, FileChange{name, "write"})
+        }
+    }
+    for name := range fw.files {
+        if _, ok := files[name]; !ok {
+            changes = append(changes, FileChange{name, "remove"})
+        }
+    }
+    fw.files = files
+    sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+    return changes
+}
+
+func (fw *FileWatcher) scan() map[string]fileStamp {
+    files := make(map[string]fileStamp)
+    for _, dir := range fw.Dirs {
+        filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+            if err != nil {
+                return nil
+            }
+            name := info.Name()
+            // skip the hidden files, e.g. .git and the swap files of the editors
+            if p != dir && strings.HasPrefix(name, ".") {
+                if info.IsDir() {
+                    return filepath.SkipDir
+                }
+                return nil
+            }
+            if !info.IsDir() {
+                files[p] = fileStamp{info.ModTime(), info.Size()}
+            }
+            return nil
+        })
+    }
+    return files
+}
+
+// CacheInvalidator is implemented by the engines which can
+// drop the cache of a changed file, e.g. DefaultTemplateEngine
+type CacheInvalidator interface {
+    Invalidate(file string)
+}
+
+// LiveReloader watches the views & static files in debug mode,
+// invalidates the caches of the changed views,
+// and tells the browsers to reload by the server-sent events.
+// the script to receive the events is injected into the html responses
+type LiveReloader struct {
+    watcher *FileWatcher
+    rh      *RequestHandler
+
+    mu      sync.Mutex
+    clients map[chan string]bool
+    done    chan struct{}
+}
+
+func createLiveReloader(rh *RequestHandler) *LiveReloader {
+    sc := rh.ServerConfig
+    staticPath := sc.StaticPath
+    if staticPath == "" {
+        staticPath = "static"
+    }
+    lr := &LiveReloader{
+        rh:      rh,
+        clients: make(map[chan string]bool),
+        done:    make(chan struct{}),
+    }
+    lr.watcher = CreateFileWatcher(lr.onChange,
+        path.Join(sc.RootDir, sc.ViewPath),
+        path.Join(sc.RootDir, staticPath))
+    return lr
+}
+
+func (lr *LiveReloader) Start() {
+    lr.watcher.Start()
+}
+
+// Stop stops watching and closes the event streams
+func (lr *LiveReloader) Stop() {
+    lr.watcher.Stop()
+    lr.mu.Lock()
+    defer lr.mu.Unlock()
+    select {
+    case <-lr.done:
+    default:
+        close(lr.done)
+    }
+}
+
+func (lr *LiveReloader) onChange(changes []FileChange) {
+    event := "css"
+    for _, c := range changes {
+        if e, ok := lr.rh.TemplateEnginer.(CacheInvalidator); ok {
+            e.Invalidate(c.Path)
+        }
+        if e, ok := lr.rh.ViewEnginer.(CacheInvalidator); ok {
+            e.Invalidate(c.Path)
+        }
+        // only the stylesheets changed, reload them without reload the page
+        if path.Ext(c.Path) != ".css" {
+            event = "reload"
+        }
+        Logger().Noticeln("live reload,", c.Op, c.Path)
+    }
+    lr.broadcast(event)
+}
+
+func (lr *LiveReloader) broadcast(event string) {
+    lr.mu.Lock()
+    defer lr.mu.Unlock()
+    for ch := range lr.clients {
+        // drop the event if the client is slow, it will reload anyway
+        select {
+        case ch <- event:
+        default:
+        }
+    }
+}
+
+// ServeHTTP streams the reload events
+func (lr *LiveReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+        return
+    }
+    ch := make(chan string, 1)
+    lr.mu.Lock()
+    lr.clients[ch] = true
+    lr.mu.Unlock()
+    defer func() {
+        lr.mu.Lock()
+        delete(lr.clients, ch)
+        lr.mu.Unlock()
+    }()
+
+    h := w.Header()
+    h.Set("Content-Type", "text/event-stream")
+    h.Set("Cache-Control", "no-cache")
+    w.WriteHeader(http.StatusOK)
+    fmt.Fprint(w, "retry: 1000\n\n")
+    flusher.Flush()
+    for {
+        select {
+        case event := <-ch:
+            fmt.Fprintf(w, "event: %s\ndata: %d\n\n", event, time.Now().UnixNano())
+            flusher.Flush()
+        case <-r.Context().Done():
+            return
+        case <-lr.done:
+            return
+        }
+    }
+}
+
+// the script receives the events, it reloads the page too
+// after reconnected, e.g. the server is restarted for the go code changes
+const liveReloadScript = `<script>(function(){
+if(!window.EventSource)return;
+var es=new EventSource("` + LIVE_RELOAD_PATH + `"),lost=false;
+es.onerror=function(){lost=true};
+es.onopen=function(){if(lost)location.reload()};
+es.addEventListener("reload",function(){location.reload()});
+es.addEventListener("css",function(){
+var ls=document.querySelectorAll('link[rel="stylesheet"]');
+for(var i=0;i<ls.length;i++){var h=ls[i].href.replace(/[?&]_lr=\d+$/,"");ls[i].href=h+(h.indexOf("?")<0?"?":"&")+"_lr="+Date.now()}
+});
+})();</script>`
+
+// inject the script before </body> of the html response
+func (lr *LiveReloader) inject(ctx *HttpContext) {
+    if ctx.RouteData != nil && ctx.RouteData.Route.IsStatic {
+        return
+    }
+    h := ctx.Header()
+    if !strings.HasPrefix(h.Get("Content-Type"), "text/html") || h.Get("Content-Encoding") != "" {
+        return
+    }
+    content := ctx.responseContentCache.Bytes()
+    i := bytes.LastIndex(content, []byte("</body>"))
+    if i < 0 {
+        return
+    }
+    script := liveReloadScript
+    // allowed by the csp of SecurityHeadersMiddleware
+    if nonce, ok := ctx.Data[CSP_NONCE_KEY].(string); ok {
+        script = `<script nonce="` + nonce + `">` + strings.TrimPrefix(script, "<script>")
+    }
+    b := make([]byte, 0, len(content)+len(script))
+    b = append(b, content[:i]...)
+    b = append(b, script...)
+    b = append(b, content[i:]...)
+    ctx.responseContentCache.Reset()
+    ctx.responseContentCache.Write(b)
+    h.Del("Content-Length")
+    // computed from the content without the script, e.g. by ETagMiddleware
+    h.Del("ETag")
+}
@@ -0,0 +1,136 @@
+package goku
+
+import (
+    "bufio"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestFileWatcher(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-watch")
+    defer os.RemoveAll(dir)
+    a := path.Join(dir, "a.html")
+    ioutil.WriteFile(a, []byte("a"), 0644)
+    ioutil.WriteFile(path.Join(dir, "b.html"), []byte("b"), 0644)
+
+    fw := CreateFileWatcher(nil, dir)
+    fw.Poll()
+    assert.Equals(t, len(fw.Poll()), 0)
+
+    os.Chtimes(a, time.Now().Add(time.Second), time.Now().Add(time.Second))
+    os.Remove(path.Join(dir, "b.html"))
+    os.MkdirAll(path.Join(dir, "shared"), 0755)
+    ioutil.WriteFile(path.Join(dir, "shared", "c.html"), []byte("c"), 0644)
+    ioutil.WriteFile(path.Join(dir, ".a.html.swp"), []byte("swap"), 0644)
+    changes := fw.Poll()
+    assert.Equals(t, len(changes), 3)
+    assert.Equals(t, changes[0], FileChange{a, "write"})
+    assert.Equals(t, changes[1], FileChange{path.Join(dir, "b.html"), "remove"})
+    assert.Equals(t, changes[2], FileChange{path.Join(dir, "shared", "c.html"), "create"})
+}
+
+func TestEngineInvalidate(t *testing.T) {
+    te := CreateDefaultTemplateEngine(true)
+    te.TemplateCache["/v/shared/layout.html_/v/home/index.html"] = nil
+    te.TemplateCache["/v/home/index.html"] = nil
+    te.TemplateCache["/v/home/about.html"] = nil
+    te.Invalidate("/v/home/index.html")
+    assert.Equals(t, strings.Join(te.CachedTemplates(), ","), "/v/home/about.html")
+
+    ve := CreateDefaultViewEngine("/v", "", "", true)
+    ve.Caches["home_index"] = "/v/home/index.html"
+    ve.Caches["home_about"] = "/v/shared/about.html"
+    ve.Invalidate("/v/home/index.html")
+    assert.Equals(t, len(ve.Caches), 1)
+    // a new view may override the shared one
+    ve.Invalidate("/v/home/about.html")
+    assert.Equals(t, len(ve.Caches), 0)
+}
+
+func TestLiveReload(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-livereload")
+    defer os.RemoveAll(dir)
+    os.MkdirAll(path.Join(dir, "views", "livereloadtest"), 0755)
+    os.MkdirAll(path.Join(dir, "static"), 0755)
+    view := path.Join(dir, "views", "livereloadtest", "index.html")
+    ioutil.WriteFile(view, []byte("<html><body>v1</body></html>"), 0644)
+
+    Controller("livereloadtest").
+        Get("index", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(nil)
+    }).
+        Get("json", func(ctx *HttpContext) ActionResulter {
+        return ctx.Json(map[string]string{"body": "</body>"})
+    })
+
+    sc := &ServerConfig{RootDir: dir, ViewPath: "views", Debug: true, LiveReload: true}
+    rh := createTestHandler(sc)
+    rh.TemplateEnginer = CreateDefaultTemplateEngine(true)
+    rh.ViewEnginer = CreateDefaultViewEngine(path.Join(dir, "views"), "", "", true)
+    rh.liveReloader = createLiveReloader(rh)
+    rh.liveReloader.watcher.files = rh.liveReloader.watcher.scan()
+    ts := httptest.NewServer(rh)
+    defer ts.Close()
+    defer rh.liveReloader.Stop()
+
+    get := func(url string) string {
+        resp, err := http.Get(ts.URL + url)
+        assert.Equals(t, err, nil)
+        defer resp.Body.Close()
+        b, _ := ioutil.ReadAll(resp.Body)
+        return string(b)
+    }
+
+    // the script is injected into the html only
+    body := get("/livereloadtest/index")
+    assert.True(t, strings.HasPrefix(body, "<html><body>v1<script>"))
+    assert.True(t, strings.HasSuffix(body, "</script></body></html>"))
+    assert.True(t, !strings.Contains(get("/livereloadtest/json"), "script"))
+
+    // with the csp nonce, and no ETag of the content without the script
+    mh := rh.MiddlewareHandler.(*DefaultMiddlewareHandle)
+    mh.AddMiddleware(CreateSecurityHeadersMiddleware(nil))
+    mh.AddMiddleware(&ETagMiddleware{})
+    resp, err := http.Get(ts.URL + "/livereloadtest/index")
+    assert.Equals(t, err, nil)
+    b, _ := ioutil.ReadAll(resp.Body)
+    resp.Body.Close()
+    nonce := regexp.MustCompile(`'nonce-([^']+)'`).FindStringSubmatch(resp.Header.Get("Content-Security-Policy"))
+    assert.Equals(t, len(nonce), 2)
+    assert.True(t, strings.HasPrefix(string(b), `<html><body>v1<script nonce="`+nonce[1]+`">`))
+    assert.Equals(t, resp.Header.Get("ETag"), "")
+    mh.Middlewares = nil
+
+    resp, err = http.Get(ts.URL + LIVE_RELOAD_PATH)
+    assert.Equals(t, err, nil)
+    defer resp.Body.Close()
+    assert.Equals(t, resp.Header.Get("Content-Type"), "text/event-stream")
+    r := bufio.NewReader(resp.Body)
+    line, _ := r.ReadString('\n')
+    assert.Equals(t, line, "retry: 1000\n")
+    r.ReadString('\n')
+
+    // the cached template is parsed again after the change
+    ioutil.WriteFile(view, []byte("<html><body>v2</body></html>"), 0644)
+    os.Chtimes(view, time.Now().Add(time.Second), time.Now().Add(time.Second))
+    rh.liveReloader.onChange(rh.liveReloader.watcher.Poll())
+    line, _ = r.ReadString('\n')
+    assert.Equals(t, line, "event: reload\n")
+    assert.True(t, strings.HasPrefix(get("/livereloadtest/index"), "<html><body>v2<script>"))
+
+    // only the stylesheets changed
+    ioutil.WriteFile(path.Join(dir, "static", "site.css"), []byte("body{}"), 0644)
+    rh.liveReloader.onChange(rh.liveReloader.watcher.Poll())
+    r.ReadString('\n')
+    r.ReadString('\n')
+    line, _ = r.ReadString('\n')
+    assert.Equals(t, line, "event: css\n")
+}
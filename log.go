@@ -12,11 +12,14 @@ const (
     LOG_LEVEL_WARN
     LOG_LEVEL_NOTICE
     LOG_LEVEL_LOG
+    LOG_LEVEL_DEBUG
 )
 
 var loger *log.Logger = log.New(os.Stdout, "", log.LstdFlags)
 
-type logger interface {
+// Loggerer is the printf-style leveled logger,
+// use GetStructLogger() for structured logging
+type Loggerer interface {
     LogLevel() int
     Log(args ...interface{})
     Logln(args ...interface{})
@@ -131,15 +134,15 @@ func (l *DefaultLogger) Errorf(format string, args ...interface{}) {
     }
 }
 
-var __logger logger = &DefaultLogger{
+var __logger Loggerer = &DefaultLogger{
     Logger:    log.New(os.Stdout, "", log.LstdFlags),
     LOG_LEVEL: LOG_LEVEL_LOG,
 }
 
-func Logger() logger {
+func Logger() Loggerer {
     return __logger
 }
 
-func SetLogger(l logger) {
+func SetLogger(l Loggerer) {
     __logger = l
 }
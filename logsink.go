@@ -0,0 +1,186 @@
+package goku
+
+import (
+    "io"
+    "log"
+    "os"
+    "sync"
+    "time"
+)
+
+// LogSink is a destination of the logs
+type LogSink struct {
+    Out     io.Writer
+    Encoder LogEncoder // TextLogEncoder if nil
+    // write the entries which level not larger than this,
+    // e.g. LOG_LEVEL_ERROR for errors only
+    Level int
+
+    mu sync.Mutex
+}
+
+func (s *LogSink) write(e *LogEntry) {
+    enc := s.Encoder
+    if enc == nil {
+        enc = &TextLogEncoder{}
+    }
+    line := enc.Encode(e)
+    s.mu.Lock()
+    s.Out.Write(line)
+    s.mu.Unlock()
+}
+
+// FanoutLogger is a StructLogger that writes to multiple sinks,
+// each sink has its own level and encoder, e.g.
+//      w, _ := goku.CreateRotateFileWriter("/var/log/myapp/app.log", 100<<20)
+//      w.Daily, w.MaxBackups = true, 30
+//      l := goku.CreateFanoutLogger(
+//          &goku.LogSink{Out: w, Level: goku.LOG_LEVEL_LOG, Encoder: &goku.JSONLogEncoder{}},
+//          &goku.LogSink{Out: os.Stderr, Level: goku.LOG_LEVEL_ERROR},
+//      )
+type FanoutLogger struct {
+    Sinks []*LogSink
+
+    fields []interface{}
+}
+
+func CreateFanoutLogger(sinks ...*LogSink) *FanoutLogger {
+    return &FanoutLogger{Sinks: sinks}
+}
+
+// LogLevel gets the largest level of the sinks
+func (l *FanoutLogger) LogLevel() int {
+    level := LOG_LEVEL_NO
+    for _, s := range l.Sinks {
+        if s.Level > level {
+            level = s.Level
+        }
+    }
+    return level
+}
+
+func (l *FanoutLogger) With(keyvals ...interface{}) StructLogger {
+    fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+    fields = append(fields, l.fields...)
+    fields = append(fields, keyvals...)
+    return &FanoutLogger{
+        Sinks:  l.Sinks,
+        fields: fields,
+    }
+}
+
+func (l *FanoutLogger) Log(level int, msg string, keyvals ...interface{}) {
+    var e *LogEntry
+    for _, s := range l.Sinks {
+        if level > s.Level {
+            continue
+        }
+        if e == nil {
+            e = &LogEntry{
+                Time:    time.Now(),
+                Level:   level,
+                Message: msg,
+                Fields:  l.fields,
+            }
+            if len(keyvals) > 0 {
+                e.Fields = append(append([]interface{}(nil), l.fields...), keyvals...)
+            }
+        }
+        s.write(e)
+    }
+}
+
+func (l *FanoutLogger) Debug(msg string, keyvals ...interface{}) {
+    l.Log(LOG_LEVEL_DEBUG, msg, keyvals...)
+}
+
+func (l *FanoutLogger) Info(msg string, keyvals ...interface{}) {
+    l.Log(LOG_LEVEL_LOG, msg, keyvals...)
+}
+
+func (l *FanoutLogger) Warn(msg string, keyvals ...interface{}) {
+    l.Log(LOG_LEVEL_WARN, msg, keyvals...)
+}
+
+func (l *FanoutLogger) Error(msg string, keyvals ...interface{}) {
+    l.Log(LOG_LEVEL_ERROR, msg, keyvals...)
+}
+
+// load the log sinks conf, like this:
+//      "LogSinks": [
+//          {
+//              "Type": "file",
+//              "File": "/var/log/goku/app.log",
+//              "Format": "json",
+//              "Level": 4,
+//              "MaxSize": 104857600,
+//              "Daily": true,
+//              "MaxBackups": 30,
+//              "MaxAge": "720h"
+//          },
+//          { "Type": "stderr", "Level": 1 }
+//      ]
+// Type is "stdout", "stderr" or "file"
+func loadLogSinksConf(v interface{}) []*LogSink {
+    list, ok := v.([]interface{})
+    if !ok {
+        log.Fatalln("conf file error: wrong LogSinks format.")
+    }
+    sinks := make([]*LogSink, 0, len(list))
+    for _, item := range list {
+        m, ok := item.(map[string]interface{})
+        if !ok {
+            log.Fatalln("conf file error: wrong LogSinks format.")
+        }
+        sink := &LogSink{Level: LOG_LEVEL_LOG}
+        if v, ok := m["Level"]; ok {
+            sink.Level = int(v.(float64))
+        }
+        if v, ok := m["Format"]; ok {
+            sink.Encoder = CreateLogEncoder(v.(string))
+        }
+        typ, _ := m["Type"].(string)
+        switch typ {
+        case "stdout", "":
+            sink.Out = os.Stdout
+        case "stderr":
+            sink.Out = os.Stderr
+        case "file":
+            sink.Out = loadRotateFileConf(m)
+        default:
+            log.Fatalln("conf file error: unknown LogSink type", typ)
+        }
+        sinks = append(sinks, sink)
+    }
+    return sinks
+}
+
+// load the RotateFileWriter conf:
+// File, MaxSize, Daily, MaxBackups and MaxAge
+func loadRotateFileConf(m map[string]interface{}) *RotateFileWriter {
+    file, _ := m["File"].(string)
+    if file == "" {
+        log.Fatalln("conf file error: File of the log must set.")
+    }
+    var maxSize int64
+    if v, ok := m["MaxSize"]; ok {
+        maxSize = int64(v.(float64))
+    }
+    w, err := CreateRotateFileWriter(file, maxSize)
+    if err != nil {
+        log.Fatalln("conf file error: can not open log file,", err)
+    }
+    if v, ok := m["Daily"]; ok {
+        w.Daily = v.(bool)
+    }
+    if v, ok := m["MaxBackups"]; ok {
+        w.MaxBackups = int(v.(float64))
+    }
+    if v, ok := m["MaxAge"]; ok {
+        w.MaxAge, err = time.ParseDuration(v.(string))
+        if err != nil {
+            log.Fatalln("conf file error: wrong MaxAge format.")
+        }
+    }
+    return w
+}
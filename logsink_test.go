@@ -0,0 +1,25 @@
+package goku
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestFanoutLogger(t *testing.T) {
+    all, errs := new(bytes.Buffer), new(bytes.Buffer)
+    l := CreateFanoutLogger(
+        &LogSink{Out: all, Level: LOG_LEVEL_LOG, Encoder: &LogfmtEncoder{}},
+        &LogSink{Out: errs, Level: LOG_LEVEL_ERROR},
+    )
+    assert.Equals(t, l.LogLevel(), LOG_LEVEL_LOG)
+    sl := l.With("module", "blog")
+    sl.Info("saved", "id", 1)
+    sl.Error("save failed")
+    sl.Debug("not logged")
+    assert.Equals(t, strings.Count(all.String(), "\n"), 2)
+    assert.StringContains(t, all.String(), "level=info msg=saved module=blog id=1\n")
+    assert.Equals(t, strings.Count(errs.String(), "\n"), 1)
+    assert.StringContains(t, errs.String(), "[ERROR] save failed module=blog\n")
+}
@@ -0,0 +1,406 @@
+package goku
+
+import (
+    "bytes"
+    "math"
+    "net/http"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// the default buckets of the histogram, in seconds
+var DefaultHistogramBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector is a metric that can write itself
+// in the Prometheus text exposition format
+type Collector interface {
+    WriteTo(b *bytes.Buffer)
+}
+
+// the label values joined as the key of the metric
+func labelKey(values []string) string {
+    return strings.Join(values, "\xff")
+}
+
+// write {name="value",...}
+func writeLabels(b *bytes.Buffer, names, values []string, extraName, extraValue string) {
+    if len(names) == 0 && extraName == "" {
+        return
+    }
+    b.WriteByte('{')
+    for i, name := range names {
+        if i > 0 {
+            b.WriteByte(',')
+        }
+        b.WriteString(name + "=\"" + escapeLabelValue(values[i]) + "\"")
+    }
+    if extraName != "" {
+        if len(names) > 0 {
+            b.WriteByte(',')
+        }
+        b.WriteString(extraName + "=\"" + extraValue + "\"")
+    }
+    b.WriteByte('}')
+}
+
+func escapeLabelValue(v string) string {
+    v = strings.Replace(v, "\\", "\\\\", -1)
+    v = strings.Replace(v, "\"", "\\\"", -1)
+    return strings.Replace(v, "\n", "\\n", -1)
+}
+
+func formatFloat(f float64) string {
+    if math.IsInf(f, 1) {
+        return "+Inf"
+    }
+    return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeHeader(b *bytes.Buffer, name, help, typ string) {
+    b.WriteString("# HELP " + name + " " + help + "\n")
+    b.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// sorted keys of the label sets, for stable output
+func sortedKeys(m map[string][]string) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// CounterVec is a counter with labels,
+// for gauge, use GaugeVec
+type CounterVec struct {
+    Name   string
+    Help   string
+    Labels []string
+
+    typ    string
+    mu     sync.Mutex
+    values map[string]float64
+    labels map[string][]string
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+    return &CounterVec{
+        Name:   name,
+        Help:   help,
+        Labels: labels,
+        typ:    "counter",
+        values: make(map[string]float64),
+        labels: make(map[string][]string),
+    }
+}
+
+// Add adds v to the counter with the label values
+func (cv *CounterVec) Add(v float64, labelValues ...string) {
+    key := labelKey(labelValues)
+    cv.mu.Lock()
+    if _, ok := cv.labels[key]; !ok {
+        cv.labels[key] = append([]string(nil), labelValues...)
+    }
+    cv.values[key] += v
+    cv.mu.Unlock()
+}
+
+func (cv *CounterVec) Inc(labelValues ...string) {
+    cv.Add(1, labelValues...)
+}
+
+// Get gets the value with the label values
+func (cv *CounterVec) Get(labelValues ...string) float64 {
+    cv.mu.Lock()
+    defer cv.mu.Unlock()
+    return cv.values[labelKey(labelValues)]
+}
+
+func (cv *CounterVec) WriteTo(b *bytes.Buffer) {
+    cv.mu.Lock()
+    defer cv.mu.Unlock()
+    writeHeader(b, cv.Name, cv.Help, cv.typ)
+    for _, key := range sortedKeys(cv.labels) {
+        b.WriteString(cv.Name)
+        writeLabels(b, cv.Labels, cv.labels[key], "", "")
+        b.WriteString(" " + formatFloat(cv.values[key]) + "\n")
+    }
+}
+
+// GaugeVec is a gauge with labels
+type GaugeVec struct {
+    CounterVec
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+    gv := &GaugeVec{*NewCounterVec(name, help, labels...)}
+    gv.typ = "gauge"
+    return gv
+}
+
+// Set sets the gauge with the label values
+func (gv *GaugeVec) Set(v float64, labelValues ...string) {
+    key := labelKey(labelValues)
+    gv.mu.Lock()
+    if _, ok := gv.labels[key]; !ok {
+        gv.labels[key] = append([]string(nil), labelValues...)
+    }
+    gv.values[key] = v
+    gv.mu.Unlock()
+}
+
+func (gv *GaugeVec) Dec(labelValues ...string) {
+    gv.Add(-1, labelValues...)
+}
+
+// HistogramVec is a histogram with labels
+type HistogramVec struct {
+    Name    string
+    Help    string
+    Labels  []string
+    Buckets []float64 // upper bounds, sorted
+
+    mu     sync.Mutex
+    series map[string]*histogramSeries
+    labels map[string][]string
+}
+
+type histogramSeries struct {
+    counts []uint64 // not cumulative
+    sum    float64
+    count  uint64
+}
+
+// NewHistogramVec creates a histogram,
+// if buckets is nil, use DefaultHistogramBuckets
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+    if buckets == nil {
+        buckets = DefaultHistogramBuckets
+    }
+    buckets = append([]float64(nil), buckets...)
+    sort.Float64s(buckets)
+    return &HistogramVec{
+        Name:    name,
+        Help:    help,
+        Labels:  labels,
+        Buckets: buckets,
+        series:  make(map[string]*histogramSeries),
+        labels:  make(map[string][]string),
+    }
+}
+
+// Observe adds a value to the histogram with the label values
+func (hv *HistogramVec) Observe(v float64, labelValues ...string) {
+    key := labelKey(labelValues)
+    hv.mu.Lock()
+    defer hv.mu.Unlock()
+    s, ok := hv.series[key]
+    if !ok {
+        s = &histogramSeries{counts: make([]uint64, len(hv.Buckets))}
+        hv.series[key] = s
+        hv.labels[key] = append([]string(nil), labelValues...)
+    }
+    for i, upper := range hv.Buckets {
+        if v <= upper {
+            s.counts[i]++
+            break
+        }
+    }
+    s.sum += v
+    s.count++
+}
+
+// ObserveDuration adds the duration since start in seconds
+func (hv *HistogramVec) ObserveDuration(start time.Time, labelValues ...string) {
+    hv.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count gets the count of the observed values with the label values
+func (hv *HistogramVec) Count(labelValues ...string) uint64 {
+    hv.mu.Lock()
+    defer hv.mu.Unlock()
+    if s, ok := hv.series[labelKey(labelValues)]; ok {
+        return s.count
+    }
+    return 0
+}
+
+func (hv *HistogramVec) WriteTo(b *bytes.Buffer) {
+    hv.mu.Lock()
+    defer hv.mu.Unlock()
+    writeHeader(b, hv.Name, hv.Help, "histogram")
+    for _, key := range sortedKeys(hv.labels) {
+        s := hv.series[key]
+        values := hv.labels[key]
+        var cumulative uint64
+        for i, upper := range hv.Buckets {
+            cumulative += s.counts[i]
+            b.WriteString(hv.Name + "_bucket")
+            writeLabels(b, hv.Labels, values, "le", formatFloat(upper))
+            b.WriteString(" " + strconv.FormatUint(cumulative, 10) + "\n")
+        }
+        b.WriteString(hv.Name + "_bucket")
+        writeLabels(b, hv.Labels, values, "le", "+Inf")
+        b.WriteString(" " + strconv.FormatUint(s.count, 10) + "\n")
+        b.WriteString(hv.Name + "_sum")
+        writeLabels(b, hv.Labels, values, "", "")
+        b.WriteString(" " + formatFloat(s.sum) + "\n")
+        b.WriteString(hv.Name + "_count")
+        writeLabels(b, hv.Labels, values, "", "")
+        b.WriteString(" " + strconv.FormatUint(s.count, 10) + "\n")
+    }
+}
+
+// MetricsRegistry holds the collectors
+type MetricsRegistry struct {
+    mu         sync.Mutex
+    collectors []Collector
+}
+
+// Register adds the collectors to the registry,
+// so they will be exposed on the metrics route
+func (mr *MetricsRegistry) Register(cs ...Collector) {
+    mr.mu.Lock()
+    mr.collectors = append(mr.collectors, cs...)
+    mr.mu.Unlock()
+}
+
+// WriteTo writes all the collectors in the Prometheus text exposition format
+func (mr *MetricsRegistry) WriteTo(b *bytes.Buffer) {
+    mr.mu.Lock()
+    cs := append([]Collector(nil), mr.collectors...)
+    mr.mu.Unlock()
+    for _, c := range cs {
+        c.WriteTo(b)
+    }
+}
+
+// the built-in metrics of goku
+var (
+    DefaultMetricsRegistry = &MetricsRegistry{}
+
+    metricRequestsTotal = NewCounterVec("goku_http_requests_total",
+        "Total number of HTTP requests.", "route", "controller", "action", "method", "code")
+    metricRequestDuration = NewHistogramVec("goku_http_request_duration_seconds",
+        "HTTP request latencies in seconds.", nil, "route", "controller", "action")
+    metricRequestsInFlight = NewGaugeVec("goku_http_requests_in_flight",
+        "Number of HTTP requests being served.")
+    metricTemplateRenderDuration = NewHistogramVec("goku_template_render_duration_seconds",
+        "Template render latencies in seconds.", nil, "view")
+    metricDBQueryDuration = NewHistogramVec("goku_db_query_duration_seconds",
+        "Database query latencies in seconds.", nil, "operation")
+
+    // whether record the metrics, set by ServerConfig.MetricsPath
+    metricsEnabled int32
+)
+
+func init() {
+    DefaultMetricsRegistry.Register(metricRequestsTotal, metricRequestDuration,
+        metricRequestsInFlight, metricTemplateRenderDuration, metricDBQueryDuration)
+}
+
+func isMetricsEnabled() bool {
+    return atomic.LoadInt32(&metricsEnabled) == 1
+}
+
+// EnableMetrics enables or disables recording the built-in metrics,
+// it is enabled if ServerConfig.MetricsPath set
+func EnableMetrics(enable bool) {
+    var v int32
+    if enable {
+        v = 1
+    }
+    atomic.StoreInt32(&metricsEnabled, v)
+}
+
+// the controller & action labels of the requests no action matched,
+// the labels from the url would be unbounded
+const METRICS_UNMATCHED = "unmatched"
+
+// the method label of the requests with a non standard method,
+// the methods from the clients would be unbounded
+const METRICS_OTHER_METHOD = "OTHER"
+
+var metricsMethods = map[string]bool{
+    "GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+    "DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+func metricsMethod(method string) string {
+    if metricsMethods[method] {
+        return method
+    }
+    return METRICS_OTHER_METHOD
+}
+
+// record the metrics of the finished request
+func recordRequestMetrics(ctx *HttpContext) {
+    route, controller, action := "", "", ""
+    if ctx.RouteData != nil {
+        route = ctx.RouteData.Route.Name
+        if ctx.actionMatched {
+            controller, action = ctx.RouteData.Controller, ctx.RouteData.Action
+        } else if !ctx.RouteData.Route.IsStatic {
+            controller, action = METRICS_UNMATCHED, METRICS_UNMATCHED
+        }
+    }
+    status := ctx.responseStatusCode
+    if status == 0 {
+        status = http.StatusOK
+    }
+    code := strconv.Itoa(status/100) + "xx"
+    metricRequestsTotal.Inc(route, controller, action, metricsMethod(ctx.Method), code)
+    metricRequestDuration.ObserveDuration(ctx.startTime, route, controller, action)
+}
+
+// the view file relative to the root dir, e.g. "views/home/index.html"
+func viewMetricName(ctx *HttpContext, viewFile string) string {
+    if rel, err := filepath.Rel(ctx.requestHandler.ServerConfig.RootDir, viewFile); err == nil {
+        return filepath.ToSlash(rel)
+    }
+    return viewFile
+}
+
+func recordTemplateRender(ctx *HttpContext, viewFile string, start time.Time) {
+    if isMetricsEnabled() {
+        metricTemplateRenderDuration.ObserveDuration(start, viewMetricName(ctx, viewFile))
+    }
+}
+
+func recordDBQuery(query string, start time.Time) {
+    if !isMetricsEnabled() {
+        return
+    }
+    metricDBQueryDuration.ObserveDuration(start, sqlOperation(query))
+}
+
+// the operation of the sql, e.g. "select", "other" if unknown
+func sqlOperation(query string) string {
+    fields := strings.Fields(query)
+    if len(fields) > 0 {
+        switch f := strings.ToLower(fields[0]); f {
+        case "select", "insert", "update", "delete", "replace":
+            return f
+        }
+    }
+    return "other"
+}
+
+// the ActionResulter for the metrics route
+type metricsResult struct {
+    Registry *MetricsRegistry
+}
+
+func (mr *metricsResult) ExecuteResult(ctx *HttpContext) {
+    var b bytes.Buffer
+    mr.Registry.WriteTo(&b)
+    ctx.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    ctx.Status(http.StatusOK)
+    ctx.WriteBuffer(&b)
+}
@@ -0,0 +1,103 @@
+package goku
+
+import (
+    "bytes"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestHistogramVec(t *testing.T) {
+    hv := NewHistogramVec("test_duration_seconds", "Test.", []float64{1, 0.1}, "op")
+    hv.Observe(0.05, "select")
+    hv.Observe(0.5, "select")
+    hv.Observe(5, "select")
+    assert.Equals(t, hv.Count("select"), uint64(3))
+    assert.Equals(t, hv.Count("insert"), uint64(0))
+
+    var b bytes.Buffer
+    hv.WriteTo(&b)
+    expected := `# HELP test_duration_seconds Test.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="select",le="0.1"} 1
+test_duration_seconds_bucket{op="select",le="1"} 2
+test_duration_seconds_bucket{op="select",le="+Inf"} 3
+test_duration_seconds_sum{op="select"} 5.55
+test_duration_seconds_count{op="select"} 3
+`
+    assert.Equals(t, b.String(), expected)
+}
+
+func TestCounterVec(t *testing.T) {
+    cv := NewCounterVec("test_total", "Test.", "path")
+    cv.Inc("/b")
+    cv.Inc("/a\"")
+    cv.Add(2, "/b")
+    assert.Equals(t, cv.Get("/b"), float64(3))
+
+    var b bytes.Buffer
+    cv.WriteTo(&b)
+    expected := `# HELP test_total Test.
+# TYPE test_total counter
+test_total{path="/a\""} 1
+test_total{path="/b"} 3
+`
+    assert.Equals(t, b.String(), expected)
+
+    gv := NewGaugeVec("test_gauge", "Test.")
+    gv.Inc()
+    gv.Inc()
+    gv.Dec()
+    assert.Equals(t, gv.Get(), float64(1))
+}
+
+func TestMetricsRoute(t *testing.T) {
+    EnableMetrics(true)
+    defer EnableMetrics(false)
+
+    Controller("metricstest").
+        Get("index", func(ctx *HttpContext) ActionResulter {
+        return ctx.Raw("ok")
+    })
+    rh := createTestHandler(&ServerConfig{MetricsPath: "/metrics"})
+
+    before := metricRequestsTotal.Get("default", "metricstest", "index", "GET", "2xx")
+    req, _ := http.NewRequest("GET", "/metricstest/index", nil)
+    rh.ServeHTTP(httptest.NewRecorder(), req)
+    req, _ = http.NewRequest("GET", "/metricstest/none", nil)
+    rh.ServeHTTP(httptest.NewRecorder(), req)
+    assert.Equals(t, metricRequestsTotal.Get("default", "metricstest", "index", "GET", "2xx"), before+1)
+    // the labels of the unmatched actions are fixed
+    assert.Equals(t, metricRequestsTotal.Get("default", "metricstest", "none", "GET", "4xx"), 0.0)
+    assert.True(t, metricRequestsTotal.Get("default", METRICS_UNMATCHED, METRICS_UNMATCHED, "GET", "4xx") >= 1)
+    assert.True(t, metricRequestDuration.Count("default", "metricstest", "index") >= 1)
+    // the non standard methods are "OTHER"
+    req, _ = http.NewRequest("FOO123", "/metricstest/index", nil)
+    rh.ServeHTTP(httptest.NewRecorder(), req)
+    assert.Equals(t, metricRequestsTotal.Get("default", METRICS_UNMATCHED, METRICS_UNMATCHED, "FOO123", "4xx"), 0.0)
+    assert.True(t, metricRequestsTotal.Get("default", METRICS_UNMATCHED, METRICS_UNMATCHED, METRICS_OTHER_METHOD, "4xx") >= 1)
+
+    w := httptest.NewRecorder()
+    req, _ = http.NewRequest("GET", "/metrics", nil)
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"))
+    body := w.Body.String()
+    assert.True(t, strings.Contains(body, `goku_http_requests_total{route="default",controller="metricstest",action="index",method="GET",code="2xx"}`))
+    assert.True(t, strings.Contains(body, `goku_http_request_duration_seconds_bucket{route="default",controller="metricstest",action="index",le="+Inf"}`))
+    // the metrics request itself is in flight
+    assert.True(t, strings.Contains(body, "goku_http_requests_in_flight 1\n"))
+}
+
+func TestRecordDBQuery(t *testing.T) {
+    EnableMetrics(true)
+    defer EnableMetrics(false)
+    before := metricDBQueryDuration.Count("select")
+    recordDBQuery("  SELECT * FROM blog", time.Now())
+    recordDBQuery("show tables", time.Now())
+    assert.Equals(t, metricDBQueryDuration.Count("select"), before+1)
+    assert.True(t, metricDBQueryDuration.Count("other") >= 1)
+}
@@ -68,3 +68,23 @@ func (mh *DefaultMiddlewareHandle) EndMvcHandle(ctx *HttpContext) (ar ActionResu
 	}
 	return
 }
+
+// BaseMiddleware implements all the methods of Middlewarer and does nothing,
+// embed it in your middleware and override the methods you need.
+type BaseMiddleware struct{}
+
+func (bm *BaseMiddleware) OnBeginRequest(ctx *HttpContext) (ActionResulter, error) {
+	return nil, nil
+}
+
+func (bm *BaseMiddleware) OnBeginMvcHandle(ctx *HttpContext) (ActionResulter, error) {
+	return nil, nil
+}
+
+func (bm *BaseMiddleware) OnEndMvcHandle(ctx *HttpContext) (ActionResulter, error) {
+	return nil, nil
+}
+
+func (bm *BaseMiddleware) OnEndRequest(ctx *HttpContext) (ActionResulter, error) {
+	return nil, nil
+}
@@ -0,0 +1,119 @@
+package goku
+
+import (
+    "errors"
+    "io"
+    "path"
+    "sync"
+)
+
+// MultiTemplateEngine holds the template engines keyed by the file ext,
+// the view is rendered by the engine of its ext, e.g.
+// the html views by DefaultTemplateEngine, the ".txt" emails by TextTemplateEngine.
+// it is created by CreateServer if ServerConfig.TemplateEngines is set,
+// and the DefaultViewEngine looks up the views of all the exts
+type MultiTemplateEngine struct {
+    mu      sync.RWMutex
+    engines map[string]TemplateEnginer
+    exts    []string // in the order registered, the first is the default
+}
+
+// CreateMultiTemplateEngine creates a MultiTemplateEngine,
+// the engines are keyed by their Ext(), the first is the default
+func CreateMultiTemplateEngine(engines ...TemplateEnginer) *MultiTemplateEngine {
+    m := &MultiTemplateEngine{engines: make(map[string]TemplateEnginer)}
+    for _, te := range engines {
+        m.Register(te.Ext(), te)
+    }
+    return m
+}
+
+// Register sets the engine of the ext, e.g. ".txt"
+func (m *MultiTemplateEngine) Register(ext string, te TemplateEnginer) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if _, ok := m.engines[ext]; !ok {
+        m.exts = append(m.exts, ext)
+    }
+    m.engines[ext] = te
+}
+
+// Engine gets the engine of the ext, nil if not registered
+func (m *MultiTemplateEngine) Engine(ext string) TemplateEnginer {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    return m.engines[ext]
+}
+
+// Exts gets the registered ext names, the default first
+func (m *MultiTemplateEngine) Exts() []string {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    return append([]string(nil), m.exts...)
+}
+
+// Ext gets the ext of the default engine
+func (m *MultiTemplateEngine) Ext() string {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    if len(m.exts) == 0 {
+        return ".html"
+    }
+    return m.exts[0]
+}
+
+func (m *MultiTemplateEngine) SupportLayout() bool {
+    return true
+}
+
+// the engine of the file, the default engine if the ext not registered
+func (m *MultiTemplateEngine) engineOf(file string) (TemplateEnginer, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    if te, ok := m.engines[path.Ext(file)]; ok {
+        return te, nil
+    }
+    if len(m.exts) == 0 {
+        return nil, errors.New("MultiTemplateEngine: no template engine registered")
+    }
+    return m.engines[m.exts[0]], nil
+}
+
+func (m *MultiTemplateEngine) Render(viewPath string, layoutPath string, viewData *ViewData, w io.Writer) error {
+    te, err := m.engineOf(viewPath)
+    if err != nil {
+        return err
+    }
+    if !te.SupportLayout() {
+        layoutPath = ""
+    }
+    return te.Render(viewPath, layoutPath, viewData, w)
+}
+
+// RenderLayouts renders by the engine of the view,
+// only the nearest layout if the engine not support the nested layouts
+func (m *MultiTemplateEngine) RenderLayouts(viewPath string, layoutPaths []string, viewData *ViewData, w io.Writer) error {
+    te, err := m.engineOf(viewPath)
+    if err != nil {
+        return err
+    }
+    if nte, ok := te.(NestedLayoutTemplateEnginer); ok {
+        return nte.RenderLayouts(viewPath, layoutPaths, viewData, w)
+    }
+    layoutPath := ""
+    if len(layoutPaths) > 0 && te.SupportLayout() {
+        layoutPath = layoutPaths[0]
+    }
+    return te.Render(viewPath, layoutPath, viewData, w)
+}
+
+// Invalidate invalidates the file in all the engines can invalidate
+func (m *MultiTemplateEngine) Invalidate(file string) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    for _, te := range m.engines {
+        if ci, ok := te.(CacheInvalidator); ok {
+            ci.Invalidate(file)
+        }
+    }
+}
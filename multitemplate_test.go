@@ -0,0 +1,62 @@
+package goku
+
+import (
+    "bytes"
+    "os"
+    "path"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestMultiTemplateEngine(t *testing.T) {
+    dir := createTestViews(t, map[string]string{
+        "shared/layout.html":   `<body>{{.Body}}</body>`,
+        "shared/layout.txt":    "{{.Body}}\n-- \n{{.Globals.SiteName}}",
+        "shared/notice.html":   `<p>shared notice</p>`,
+        "mail/welcome.txt":     `Hi {{.Model.Name}}, {{truncate 5 .Model.Bio}} {{section "ps"}}{{define "ps"}}P.S. <b>bye</b>{{end}}`,
+        "mail/notice.txt":      `mail notice`,
+        "mail/receipt.html":    `<p>{{.Model.Name}}</p>`,
+        "mail/_signature.txt":  `{{.Model.Name}}`,
+        "mail/with_partial.txt": `thanks, {{partial "_signature" .}}`,
+    })
+    defer os.RemoveAll(dir)
+    defer setTestGlobalViewData("SiteName", "goku")()
+
+    mte := CreateMultiTemplateEngine(CreateDefaultTemplateEngine(true), CreateTextTemplateEngine(true))
+    assert.Equals(t, mte.Ext(), ".html")
+    assert.Equals(t, len(mte.Exts()), 2)
+    ve := CreateDefaultViewEngine(dir, "", mte.Ext(), true)
+    ve.ExtNames = mte.Exts()[1:]
+
+    render := func(view string) string {
+        vr := &ViewResult{
+            ViewEngine:     ve,
+            TemplateEngine: mte,
+            ViewName:       view,
+            ViewModel:      map[string]string{"Name": "<Lulu>", "Bio": "gopher and writer"},
+        }
+        ctx, _ := createTestContext("GET", "/mail/send", nil)
+        ctx.RouteData = &RouteData{Controller: "mail", Action: "send"}
+        var b bytes.Buffer
+        if err := vr.Render(ctx, &b); err != nil {
+            return err.Error()
+        }
+        return b.String()
+    }
+
+    // the text template with the text layout, not escaped
+    assert.Equals(t, render("welcome"), "Hi <Lulu>, gophe... P.S. <b>bye</b>\n-- \ngoku")
+    // the html template with the html layout
+    assert.Equals(t, render("receipt"), "<body><p>&lt;Lulu&gt;</p></body>")
+    // the controller's view first, whatever the ext
+    assert.Equals(t, render("notice"), "mail notice\n-- \ngoku")
+    // the view name with the ext
+    assert.Equals(t, render("/shared/notice.html"), "<body><p>shared notice</p></body>")
+    // the partial is rendered by the engine of its ext
+    assert.Equals(t, render("with_partial"), "thanks, <Lulu>\n-- \ngoku")
+
+    welcome := path.Join(dir, "mail/welcome.txt")
+    assert.Equals(t, mte.Engine(".txt").(*TextTemplateEngine).cache[path.Join(dir, "shared/layout.txt")+"_"+welcome] != nil, true)
+    mte.Invalidate(welcome)
+    assert.Equals(t, len(mte.Engine(".txt").(*TextTemplateEngine).cache), 3)
+}
@@ -0,0 +1,307 @@
+package goku
+
+import (
+    "bytes"
+    "container/list"
+    "fmt"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// the cached response of an action
+type CachedOutput struct {
+    StatusCode int
+    Headers    map[string]string
+    Body       []byte
+    Tags       []string
+    Expires    time.Time
+}
+
+// size of the cached output, for the store's size limit
+func (co *CachedOutput) Size() int {
+    n := len(co.Body)
+    for k, v := range co.Headers {
+        n += len(k) + len(v)
+    }
+    return n
+}
+
+// create an ActionResulter from the cached output,
+// the body is copied for the ActionResult.Body will be drained after execute
+func (co *CachedOutput) actionResult() *ActionResult {
+    headers := make(map[string]string, len(co.Headers))
+    for k, v := range co.Headers {
+        headers[k] = v
+    }
+    return &ActionResult{
+        StatusCode: co.StatusCode,
+        Headers:    headers,
+        Body:       bytes.NewBuffer(append([]byte(nil), co.Body...)),
+    }
+}
+
+// OutputCacheStore is the storage of the output cache,
+// the default is a memory LRU store
+type OutputCacheStore interface {
+    Get(key string) (*CachedOutput, bool)
+    Set(key string, item *CachedOutput)
+    Delete(key string)
+    // delete all the items which has the tag
+    DeleteByTag(tag string)
+}
+
+// MemoryOutputCacheStore is a LRU store in memory
+type MemoryOutputCacheStore struct {
+    MaxEntries int // max number of the items, no limit if 0
+    MaxBytes   int // max total size of the items, no limit if 0
+
+    mu    sync.Mutex
+    ll    *list.List
+    items map[string]*list.Element
+    tags  map[string]map[string]bool // tag => keys
+    size  int
+}
+
+type memoryCacheEntry struct {
+    key  string
+    item *CachedOutput
+}
+
+// CreateMemoryOutputCacheStore creates a memory LRU store
+func CreateMemoryOutputCacheStore(maxEntries, maxBytes int) *MemoryOutputCacheStore {
+    return &MemoryOutputCacheStore{
+        MaxEntries: maxEntries,
+        MaxBytes:   maxBytes,
+        ll:         list.New(),
+        items:      make(map[string]*list.Element),
+        tags:       make(map[string]map[string]bool),
+    }
+}
+
+func (ms *MemoryOutputCacheStore) Get(key string) (*CachedOutput, bool) {
+    ms.mu.Lock()
+    defer ms.mu.Unlock()
+    el, ok := ms.items[key]
+    if !ok {
+        return nil, false
+    }
+    item := el.Value.(*memoryCacheEntry).item
+    if time.Now().After(item.Expires) {
+        ms.removeElement(el)
+        return nil, false
+    }
+    ms.ll.MoveToFront(el)
+    return item, true
+}
+
+func (ms *MemoryOutputCacheStore) Set(key string, item *CachedOutput) {
+    ms.mu.Lock()
+    defer ms.mu.Unlock()
+    if el, ok := ms.items[key]; ok {
+        ms.removeElement(el)
+    }
+    if ms.MaxBytes > 0 && item.Size() > ms.MaxBytes {
+        return
+    }
+    ms.items[key] = ms.ll.PushFront(&memoryCacheEntry{key: key, item: item})
+    ms.size += item.Size()
+    for _, tag := range item.Tags {
+        if ms.tags[tag] == nil {
+            ms.tags[tag] = make(map[string]bool)
+        }
+        ms.tags[tag][key] = true
+    }
+    for (ms.MaxEntries > 0 && ms.ll.Len() > ms.MaxEntries) ||
+        (ms.MaxBytes > 0 && ms.size > ms.MaxBytes) {
+        ms.removeElement(ms.ll.Back())
+    }
+}
+
+func (ms *MemoryOutputCacheStore) Delete(key string) {
+    ms.mu.Lock()
+    defer ms.mu.Unlock()
+    if el, ok := ms.items[key]; ok {
+        ms.removeElement(el)
+    }
+}
+
+func (ms *MemoryOutputCacheStore) DeleteByTag(tag string) {
+    ms.mu.Lock()
+    defer ms.mu.Unlock()
+    for key := range ms.tags[tag] {
+        if el, ok := ms.items[key]; ok {
+            ms.removeElement(el)
+        }
+    }
+    delete(ms.tags, tag)
+}
+
+// Len gets the number of the items in the store
+func (ms *MemoryOutputCacheStore) Len() int {
+    ms.mu.Lock()
+    defer ms.mu.Unlock()
+    return ms.ll.Len()
+}
+
+func (ms *MemoryOutputCacheStore) removeElement(el *list.Element) {
+    entry := el.Value.(*memoryCacheEntry)
+    ms.ll.Remove(el)
+    delete(ms.items, entry.key)
+    ms.size -= entry.item.Size()
+    for _, tag := range entry.item.Tags {
+        if keys, ok := ms.tags[tag]; ok {
+            delete(keys, entry.key)
+            if len(keys) == 0 {
+                delete(ms.tags, tag)
+            }
+        }
+    }
+}
+
+// the default store for OutputCache,
+// 1000 items and 64MB at most
+var DefaultOutputCacheStore OutputCacheStore = CreateMemoryOutputCacheStore(1000, 64<<20)
+
+// InvalidateOutputCache deletes all the cached output which has the tag
+// from the DefaultOutputCacheStore
+func InvalidateOutputCache(tag string) {
+    DefaultOutputCacheStore.DeleteByTag(tag)
+}
+
+// the response headers stored with the cached output,
+// the others may be per request, e.g. X-Request-Id, Set-Cookie or the CORS headers
+var outputCacheHeaders = []string{
+    "Content-Type",
+    "Content-Encoding",
+    "Content-Language",
+    "Cache-Control",
+    "ETag",
+    "Last-Modified",
+}
+
+// OutputCache is a filter that caches the output of an action.
+// only the GET & HEAD requests with status code 200 will be cached,
+// and the response with Set-Cookie header will not be cached.
+// only the representation headers are cached, see outputCacheHeaders.
+//      goku.Controller("blog").
+//          Get("index", blogIndex).
+//          Filters(&goku.OutputCache{
+//              Duration:     10 * time.Second,
+//              VaryByParams: []string{"page"},
+//              Tags:         []string{"blog"},
+//          })
+//      // when a blog updated
+//      goku.InvalidateOutputCache("blog")
+type OutputCache struct {
+    BaseFilter
+
+    Duration time.Duration // how long the output will be cached
+    // query params that the cache varies by,
+    // "*" for all the params, none if empty
+    VaryByParams []string
+    // request headers that the cache varies by, e.g. "Accept-Language"
+    VaryByHeaders []string
+    // whether the cache varies by ctx.User
+    VaryByUser bool
+    // tags for invalidation
+    Tags []string
+    // dynamic tags for invalidation, e.g. "blog-" + ctx.Get("id")
+    TagsFunc func(ctx *HttpContext) []string
+    // the output larger than this will not be cached, no limit if 0
+    MaxBodySize int
+    // the store, DefaultOutputCacheStore if nil
+    Store OutputCacheStore
+}
+
+func (oc *OutputCache) store() OutputCacheStore {
+    if oc.Store != nil {
+        return oc.Store
+    }
+    return DefaultOutputCacheStore
+}
+
+// the key in ctx.Data to pass the cache key to OnResultExecuted
+func (oc *OutputCache) dataKey() string {
+    return fmt.Sprintf("__goku_outputcache_%p", oc)
+}
+
+func (oc *OutputCache) cacheKey(ctx *HttpContext) string {
+    var b bytes.Buffer
+    b.WriteString(ctx.Method + " " + ctx.Request.URL.Path)
+    if len(oc.VaryByParams) > 0 {
+        query := ctx.Request.URL.Query()
+        // a copy, the filter is shared by the concurrent requests
+        names := append([]string(nil), oc.VaryByParams...)
+        if len(names) == 1 && names[0] == "*" {
+            names = make([]string, 0, len(query))
+            for name := range query {
+                names = append(names, name)
+            }
+        }
+        sort.Strings(names)
+        b.WriteString("?")
+        for _, name := range names {
+            for _, v := range query[name] {
+                b.WriteString(url.QueryEscape(name) + "=" + url.QueryEscape(v) + "&")
+            }
+        }
+    }
+    for _, name := range oc.VaryByHeaders {
+        b.WriteString("|" + strings.ToLower(name) + ":" + ctx.GetHeader(name))
+    }
+    if oc.VaryByUser {
+        b.WriteString("|user:" + ctx.User)
+    }
+    return b.String()
+}
+
+func (oc *OutputCache) OnActionExecuting(ctx *HttpContext) (ActionResulter, error) {
+    if oc.Duration <= 0 || (ctx.Method != "GET" && ctx.Method != "HEAD") {
+        return nil, nil
+    }
+    key := oc.cacheKey(ctx)
+    if item, ok := oc.store().Get(key); ok {
+        return item.actionResult(), nil
+    }
+    ctx.Data[oc.dataKey()] = key
+    return nil, nil
+}
+
+func (oc *OutputCache) OnResultExecuted(ctx *HttpContext) (ActionResulter, error) {
+    key, ok := ctx.Data[oc.dataKey()].(string)
+    if !ok {
+        return nil, nil
+    }
+    delete(ctx.Data, oc.dataKey())
+    status := ctx.responseStatusCode
+    if status == 0 {
+        status = http.StatusOK
+    }
+    if status != http.StatusOK || ctx.Header().Get("Set-Cookie") != "" {
+        return nil, nil
+    }
+    if oc.MaxBodySize > 0 && ctx.responseContentCache.Len() > oc.MaxBodySize {
+        return nil, nil
+    }
+    item := &CachedOutput{
+        StatusCode: status,
+        Headers:    make(map[string]string),
+        Body:       append([]byte(nil), ctx.responseContentCache.Bytes()...),
+        Tags:       oc.Tags,
+        Expires:    time.Now().Add(oc.Duration),
+    }
+    if oc.TagsFunc != nil {
+        item.Tags = append(append([]string(nil), oc.Tags...), oc.TagsFunc(ctx)...)
+    }
+    for _, k := range outputCacheHeaders {
+        if v := ctx.Header().Values(k); len(v) > 0 {
+            item.Headers[k] = strings.Join(v, ", ")
+        }
+    }
+    oc.store().Set(key, item)
+    return nil, nil
+}
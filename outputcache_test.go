@@ -0,0 +1,121 @@
+package goku
+
+import (
+    "strings"
+    "sync"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestMemoryOutputCacheStore(t *testing.T) {
+    ms := CreateMemoryOutputCacheStore(2, 0)
+    expires := time.Now().Add(time.Minute)
+    ms.Set("a", &CachedOutput{Body: []byte("a"), Tags: []string{"t1"}, Expires: expires})
+    ms.Set("b", &CachedOutput{Body: []byte("b"), Tags: []string{"t2"}, Expires: expires})
+    ms.Get("a")
+    ms.Set("c", &CachedOutput{Body: []byte("c"), Tags: []string{"t1"}, Expires: expires})
+    // b is the least recently used
+    _, ok := ms.Get("b")
+    assert.Equals(t, ok, false)
+    assert.Equals(t, ms.Len(), 2)
+
+    ms.DeleteByTag("t1")
+    assert.Equals(t, ms.Len(), 0)
+
+    ms.Set("d", &CachedOutput{Body: []byte("d"), Expires: time.Now().Add(-time.Second)})
+    _, ok = ms.Get("d")
+    assert.Equals(t, ok, false)
+
+    ms = CreateMemoryOutputCacheStore(0, 10)
+    ms.Set("a", &CachedOutput{Body: []byte("123456"), Expires: expires})
+    ms.Set("b", &CachedOutput{Body: []byte("123456"), Expires: expires})
+    assert.Equals(t, ms.Len(), 1)
+}
+
+func TestOutputCacheFilter(t *testing.T) {
+    oc := &OutputCache{
+        Duration:     time.Minute,
+        VaryByParams: []string{"page"},
+        Tags:         []string{"blog"},
+        Store:        CreateMemoryOutputCacheStore(10, 0),
+    }
+    ctx, _ := createTestContext("GET", "/blog/index?page=2&t=1", nil)
+    ar, _ := oc.OnActionExecuting(ctx)
+    assert.Equals(t, ar, nil)
+    ctx.ContentType("text/html")
+    ctx.WriteString("page 2")
+    oc.OnResultExecuted(ctx)
+
+    // other params not in VaryByParams
+    ctx, w := createTestContext("GET", "/blog/index?t=2&page=2", nil)
+    ar, _ = oc.OnActionExecuting(ctx)
+    assert.NotEquals(t, ar, nil)
+    ar.ExecuteResult(ctx)
+    ctx.flushToResponse()
+    assert.Equals(t, w.Body.String(), "page 2")
+    assert.Equals(t, w.Header().Get("Content-Type"), "text/html")
+
+    // cached output can be used more than once
+    ctx, w = createTestContext("GET", "/blog/index?page=2", nil)
+    ar, _ = oc.OnActionExecuting(ctx)
+    ar.ExecuteResult(ctx)
+    ctx.flushToResponse()
+    assert.Equals(t, w.Body.String(), "page 2")
+
+    ctx, _ = createTestContext("GET", "/blog/index?page=3", nil)
+    ar, _ = oc.OnActionExecuting(ctx)
+    assert.Equals(t, ar, nil)
+
+    oc.Store.DeleteByTag("blog")
+    ctx, _ = createTestContext("GET", "/blog/index?page=2", nil)
+    ar, _ = oc.OnActionExecuting(ctx)
+    assert.Equals(t, ar, nil)
+}
+
+func TestOutputCacheHeaders(t *testing.T) {
+    oc := &OutputCache{
+        Duration: time.Minute,
+        Store:    CreateMemoryOutputCacheStore(10, 0),
+    }
+    ctx, _ := createTestContext("GET", "/blog/index", nil)
+    oc.OnActionExecuting(ctx)
+    ctx.ContentType("text/html")
+    ctx.SetHeader("ETag", `"v1"`)
+    ctx.SetHeader("X-Request-Id", "req-1")
+    ctx.SetHeader("Access-Control-Allow-Origin", "https://a.example.com")
+    ctx.WriteString("index")
+    oc.OnResultExecuted(ctx)
+
+    // only the representation headers are replayed
+    ctx, w := createTestContext("GET", "/blog/index", nil)
+    ar, _ := oc.OnActionExecuting(ctx)
+    ar.ExecuteResult(ctx)
+    ctx.flushToResponse()
+    assert.Equals(t, w.Header().Get("Content-Type"), "text/html")
+    assert.Equals(t, w.Header().Get("ETag"), `"v1"`)
+    assert.True(t, w.Header().Get("X-Request-Id") != "req-1")
+    assert.Equals(t, w.Header().Get("Access-Control-Allow-Origin"), "")
+
+    // the method is in the key
+    ctx, _ = createTestContext("HEAD", "/blog/index", nil)
+    ar, _ = oc.OnActionExecuting(ctx)
+    assert.Equals(t, ar, nil)
+}
+
+func TestOutputCacheKeyConcurrent(t *testing.T) {
+    oc := &OutputCache{VaryByParams: []string{"page", "id"}}
+    var wg sync.WaitGroup
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            ctx, _ := createTestContext("GET", "/blog/index?page=2&id=1", nil)
+            if key := oc.cacheKey(ctx); key != "GET /blog/index?id=1&page=2&" {
+                t.Error("wrong key:", key)
+            }
+        }()
+    }
+    wg.Wait()
+    assert.Equals(t, strings.Join(oc.VaryByParams, ","), "page,id")
+}
@@ -0,0 +1,255 @@
+package goku
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "path"
+    "runtime"
+    "strings"
+)
+
+// StackFrame is a frame of the stack
+type StackFrame struct {
+    Function string
+    File     string
+    Line     int
+    // false if the frame is in the runtime, net/http or goku itself
+    User bool
+}
+
+// PanicInfo is the info about a recovered panic
+type PanicInfo struct {
+    Value     interface{} // the value passed to panic
+    Frames    []StackFrame
+    RequestId string
+}
+
+// Err gets the panic value as an error
+func (pi *PanicInfo) Err() error {
+    if err, ok := pi.Value.(error); ok {
+        return err
+    }
+    return fmt.Errorf("panic: %v", pi.Value)
+}
+
+// UserFrames gets the frames of the user's code,
+// all the frames if no user frame
+func (pi *PanicInfo) UserFrames() []StackFrame {
+    frames := make([]StackFrame, 0, len(pi.Frames))
+    for _, f := range pi.Frames {
+        if f.User {
+            frames = append(frames, f)
+        }
+    }
+    if len(frames) == 0 {
+        return pi.Frames
+    }
+    return frames
+}
+
+// Stack formats the user's frames like debug.Stack
+func (pi *PanicInfo) Stack() string {
+    var b bytes.Buffer
+    for _, f := range pi.UserFrames() {
+        fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+    }
+    return b.String()
+}
+
+// PanicHook is called for every recovered panic,
+// e.g. report it to an error tracker
+type PanicHook func(ctx *HttpContext, pi *PanicInfo)
+
+// PanicHandler makes the response of a recovered panic,
+// the partial output is discarded before it is called
+type PanicHandler func(ctx *HttpContext, pi *PanicInfo) ActionResulter
+
+// the dir of the goku package, the frames in it are not the user's
+var gokuDir = func() string {
+    _, file, _, _ := runtime.Caller(0)
+    return path.Dir(file)
+}()
+
+// capture the stack of the panic in the current goroutine,
+// start from the frame calling panic
+func capturePanicFrames() []StackFrame {
+    pcs := make([]uintptr, 64)
+    n := runtime.Callers(1, pcs)
+    frames := runtime.CallersFrames(pcs[:n])
+    var all []StackFrame
+    for {
+        f, more := frames.Next()
+        all = append(all, StackFrame{
+            Function: f.Function,
+            File:     f.File,
+            Line:     f.Line,
+            User:     isUserFrame(f.Function, f.File),
+        })
+        if !more {
+            break
+        }
+    }
+    // skip the frames of the recover and the panic itself
+    start := 0
+    for i, f := range all {
+        if f.Function == "runtime.gopanic" {
+            start = i + 1
+            break
+        }
+    }
+    for start < len(all) && strings.HasPrefix(all[start].Function, "runtime.") {
+        start++
+    }
+    if start >= len(all) {
+        return all
+    }
+    return all[start:]
+}
+
+func isUserFrame(function, file string) bool {
+    if strings.HasPrefix(function, "runtime.") || strings.HasPrefix(function, "net/http.") ||
+        strings.HasPrefix(function, "testing.") {
+        return false
+    }
+    return path.Dir(file) != gokuDir || strings.HasSuffix(file, "_test.go")
+}
+
+// handle the recovered panic, returns the result to response
+func (rh *RequestHandler) recoverPanic(ctx *HttpContext, v interface{}) ActionResulter {
+    pi := &PanicInfo{
+        Value:     v,
+        Frames:    capturePanicFrames(),
+        RequestId: ctx.requestId,
+    }
+    if Logger().LogLevel() >= LOG_LEVEL_ERROR || rh.ServerConfig.Debug {
+        Logger().Errorln("request_id="+ctx.requestId, fmt.Sprintf("%v", v), "\n", pi.Stack())
+    }
+    ctx.span.SetError(pi.Err())
+    for _, hook := range rh.ServerConfig.PanicHooks {
+        runPanicHook(hook, ctx, pi)
+    }
+    // the response is sent, can not change it
+    if ctx.responseCommitted() {
+        ctx.Canceled = true
+        ctx.discardResponse()
+        return nil
+    }
+    ctx.discardResponse()
+    h := rh.ServerConfig.PanicHandler
+    if h == nil {
+        h = DefaultPanicHandler
+    }
+    return h(ctx, pi)
+}
+
+// a panic in the hook must not break the response
+func runPanicHook(hook PanicHook, ctx *HttpContext, pi *PanicInfo) {
+    defer func() {
+        if e := recover(); e != nil {
+            Logger().Errorln("request_id="+ctx.requestId, "panic hook panic,", e)
+        }
+    }()
+    hook(ctx, pi)
+}
+
+// DefaultPanicHandler shows the dev error page, or json for the json requests in debug mode,
+// otherwise it is the same as ctx.Error("Internal Server Error")
+func DefaultPanicHandler(ctx *HttpContext, pi *PanicInfo) ActionResulter {
+    if ctx.requestHandler.ServerConfig.Debug {
+        if ctx.WantsJson() {
+            frames := pi.UserFrames()
+            stack := make([]string, 0, len(frames))
+            for _, f := range frames {
+                stack = append(stack, fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line))
+            }
+            b, _ := json.Marshal(map[string]interface{}{
+                "status":     http.StatusInternalServerError,
+                "error":      http.StatusText(http.StatusInternalServerError),
+                "message":    fmt.Sprintf("%v", pi.Value),
+                "request_id": pi.RequestId,
+                "stack":      stack,
+            })
+            return &ActionResult{
+                StatusCode:      http.StatusInternalServerError,
+                Headers:         map[string]string{"Content-Type": "application/json; charset=utf-8"},
+                Body:            bytes.NewBuffer(b),
+                notShowDevError: true,
+            }
+        }
+        return &devErrorResult{
+            StatusCode: http.StatusInternalServerError,
+            Err:        fmt.Sprintf("%v", pi.Value),
+            ShowDetail: true,
+            Stack:      pi.Stack(),
+            Frames:     pi.Frames,
+            Cause:      pi.Err(),
+        }
+    }
+    er := createErrorResult(http.StatusInternalServerError, "Internal Server Error")
+    er.Err = pi.Err()
+    return er
+}
+
+// the headers describe the discarded content
+var discardedHeaders = []string{"Content-Type", "Content-Length", "Content-Encoding",
+    "Content-Disposition", "ETag", "Last-Modified", "Location"}
+
+// discard the buffered output
+func (ctx *HttpContext) discardResponse() {
+    ctx.responseContentCache.Reset()
+    ctx.responseStatusCode = 0
+    h := ctx.responseWriter.Header()
+    for _, name := range discardedHeaders {
+        h.Del(name)
+    }
+}
+
+// whether the response header is sent
+func (ctx *HttpContext) responseCommitted() bool {
+    if w, ok := ctx.responseWriter.(*responseWriter); ok {
+        return w.committed
+    }
+    return false
+}
+
+// responseWriter records whether the response is committed,
+// e.g. written by ctx.ResponseWriter() directly
+type responseWriter struct {
+    http.ResponseWriter
+    committed bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+    w.committed = true
+    w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+    w.committed = true
+    return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+    if f, ok := w.ResponseWriter.(http.Flusher); ok {
+        w.committed = true
+        f.Flush()
+    }
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+        w.committed = true
+        return h.Hijack()
+    }
+    return nil, nil, errors.New("goku: the ResponseWriter does not support Hijack")
+}
+
+// Unwrap is for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+    return w.ResponseWriter
+}
@@ -0,0 +1,90 @@
+package goku
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func panicInUserCode() {
+    var m map[string]int
+    m["a"] = 1
+}
+
+func TestPanicRecovery(t *testing.T) {
+    Controller("recoverytest").
+        Get("partial", func(ctx *HttpContext) ActionResulter {
+        ctx.SetHeader("Content-Type", "text/csv")
+        ctx.WriteString("id,name\n1,")
+        panicInUserCode()
+        return nil
+    }).
+        Get("committed", func(ctx *HttpContext) ActionResulter {
+        ctx.ResponseWriter().Write([]byte("streaming..."))
+        panic("boom")
+    })
+
+    var hooked *PanicInfo
+    sc := &ServerConfig{
+        PanicHooks: []PanicHook{
+            func(ctx *HttpContext, pi *PanicInfo) {
+                panic("the hook is broken")
+            },
+            func(ctx *HttpContext, pi *PanicInfo) {
+                hooked = pi
+            },
+        },
+    }
+    rh := createTestHandler(sc)
+
+    get := func(url, accept string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", url, nil)
+        req.Header.Set("Accept", accept)
+        rh.ServeHTTP(w, req)
+        return w
+    }
+
+    // the partial output is discarded
+    w := get("/recoverytest/partial", "application/json")
+    assert.Equals(t, w.Code, http.StatusInternalServerError)
+    assert.Equals(t, w.Header().Get("Content-Type"), "application/json; charset=utf-8")
+    assert.True(t, !strings.Contains(w.Body.String(), "id,name"))
+    assert.True(t, strings.Contains(w.Body.String(), `"message":"Internal Server Error"`))
+
+    // the hook gets the user's frames
+    assert.True(t, hooked != nil)
+    assert.Equals(t, hooked.RequestId, w.Header().Get(REQUEST_ID_HEADER))
+    assert.True(t, strings.Contains(hooked.Err().Error(), "nil map"))
+    frames := hooked.UserFrames()
+    assert.Equals(t, frames[0].Function, "github.com/QLeelulu/goku.panicInUserCode")
+    for _, f := range frames {
+        assert.True(t, !strings.HasPrefix(f.Function, "runtime."))
+        assert.True(t, !strings.Contains(f.Function, "RequestHandler"))
+    }
+    assert.True(t, strings.HasPrefix(hooked.Stack(), "github.com/QLeelulu/goku.panicInUserCode\n\t"))
+
+    // debug mode, json for the api
+    sc.Debug = true
+    w = get("/recoverytest/partial", "application/json")
+    assert.Equals(t, w.Code, http.StatusInternalServerError)
+    assert.True(t, strings.Contains(w.Body.String(), `"stack":["github.com/QLeelulu/goku.panicInUserCode `))
+    sc.Debug = false
+
+    // custom handler
+    sc.PanicHandler = func(ctx *HttpContext, pi *PanicInfo) ActionResulter {
+        return ctx.Html("<h1>oops</h1>")
+    }
+    w = get("/recoverytest/partial", "text/html")
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.Equals(t, w.Body.String(), "<h1>oops</h1>")
+    assert.Equals(t, w.Header().Get("Content-Type"), "text/html")
+
+    // the response was committed, can not be replaced
+    w = get("/recoverytest/committed", "")
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.Equals(t, w.Body.String(), "streaming...")
+    assert.Equals(t, hooked.Value, "boom")
+}
@@ -0,0 +1,90 @@
+package goku
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "sync"
+)
+
+// the engines used by RenderView, set by CreateServer
+var defaultViewEngines struct {
+    sync.RWMutex
+    viewEngine     ViewEnginer
+    templateEngine TemplateEnginer
+}
+
+// SetViewEngines sets the engines used by RenderView.
+// CreateServer sets the engines of the server,
+// call it to render the views without a server, e.g. in a background worker:
+//      te := goku.CreateDefaultTemplateEngine(true)
+//      goku.SetViewEngines(goku.CreateDefaultViewEngine("views", "layout", te.Ext(), true), te)
+func SetViewEngines(ve ViewEnginer, te TemplateEnginer) {
+    defaultViewEngines.Lock()
+    defer defaultViewEngines.Unlock()
+    defaultViewEngines.viewEngine = ve
+    defaultViewEngines.templateEngine = te
+}
+
+// RenderView renders the view to string outside the request pipeline,
+// e.g. the email bodies, by the engines of the server and the global view data.
+// the view is found like ctx.Render, in the views of the controller and the shared views,
+// the layout is the default one or set by the layout directive of the view:
+//      body, err := goku.RenderView("welcome", "mail", user, map[string]interface{}{"Url": url})
+func RenderView(viewName, controller string, model interface{}, data map[string]interface{}) (string, error) {
+    defaultViewEngines.RLock()
+    ve, te := defaultViewEngines.viewEngine, defaultViewEngines.templateEngine
+    defaultViewEngines.RUnlock()
+    if ve == nil || te == nil {
+        return "", errors.New("RenderView: no view engines, create the server or call SetViewEngines first")
+    }
+    if viewName == "" {
+        return "", errors.New("RenderView: the view name is empty")
+    }
+    vi := &ViewInfo{
+        Controller: controller,
+        View:       viewName,
+    }
+    viewData := &ViewData{
+        Data:    data,
+        Model:   model,
+        Globals: getGlobalViewData(),
+        renderer: &viewRenderer{
+            viewEngine:     ve,
+            templateEngine: te,
+            controller:     controller,
+        },
+    }
+    viewFile, layoutFiles, err := findViewFiles(ve, vi)
+    if err != nil {
+        return "", err
+    }
+    var b bytes.Buffer
+    if err = renderViewFiles(te, viewFile, layoutFiles, viewData, &b); err != nil {
+        return "", err
+    }
+    return b.String(), nil
+}
+
+// find the view and its layouts, the nearest layout first
+func findViewFiles(ve ViewEnginer, vi *ViewInfo) (viewFile string, layoutFiles []string, err error) {
+    if nve, ok := ve.(NestedLayoutViewEnginer); ok {
+        return nve.FindLayouts(vi)
+    }
+    viewFile, layoutFile, err := ve.FindView(vi)
+    if layoutFile != "" {
+        layoutFiles = []string{layoutFile}
+    }
+    return
+}
+
+// render the view in the layouts, only the nearest layout
+// if the template engine not support the nested layouts
+func renderViewFiles(te TemplateEnginer, viewFile string, layoutFiles []string, viewData *ViewData, wr io.Writer) error {
+    if nte, ok := te.(NestedLayoutTemplateEnginer); ok {
+        return nte.RenderLayouts(viewFile, layoutFiles, viewData, wr)
+    } else if len(layoutFiles) > 0 {
+        return te.Render(viewFile, layoutFiles[0], viewData, wr)
+    }
+    return te.Render(viewFile, "", viewData, wr)
+}
@@ -0,0 +1,88 @@
+package goku
+
+import (
+    "os"
+    "strings"
+    "sync"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+// sets the global view data for the test, returns the func restores it
+func setTestGlobalViewData(key string, val interface{}) func() {
+    old := getGlobalViewData()
+    SetGlobalViewData(key, val)
+    return func() {
+        globalViewData.Lock()
+        defer globalViewData.Unlock()
+        globalViewData.data = old
+    }
+}
+
+func TestRenderView(t *testing.T) {
+    SetViewEngines(nil, nil)
+    _, err := RenderView("welcome", "mail", nil, nil)
+    assert.True(t, err != nil)
+
+    dir := createTestViews(t, map[string]string{
+        "shared/layout.html":  `<body>{{.Body}}</body>`,
+        "shared/footer.html":  `{{/* layout: none */}}footer of {{.Globals.SiteName}}`,
+        "mail/welcome.html":   `Hi {{.Model}}, {{.Data.Url}} {{partial "footer" .}}`,
+        "mail/plain.html":     `{{/* layout: none */}}Hi {{.Model}}`,
+        "mail/broken.html":    `before {{.Model.Name.Missing}} after`,
+    })
+    defer os.RemoveAll(dir)
+    te := CreateDefaultTemplateEngine(true)
+    SetViewEngines(CreateDefaultViewEngine(dir, "layout", te.Ext(), true), te)
+    defer SetViewEngines(nil, nil)
+    defer setTestGlobalViewData("SiteName", "goku")()
+
+    s, err := RenderView("welcome", "mail", "<Lulu>", map[string]interface{}{"Url": "/go"})
+    assert.Equals(t, err, nil)
+    assert.Equals(t, s, "<body>Hi &lt;Lulu&gt;, /go footer of goku</body>")
+
+    // the layout directive of the view
+    s, err = RenderView("plain", "mail", "Lulu", nil)
+    assert.Equals(t, err, nil)
+    assert.Equals(t, s, "Hi Lulu")
+
+    // the shared view without the controller
+    s, err = RenderView("footer", "", nil, nil)
+    assert.Equals(t, err, nil)
+    assert.Equals(t, s, "footer of goku")
+
+    // the errors, no partial output
+    s, err = RenderView("missing", "mail", nil, nil)
+    assert.True(t, err != nil)
+    assert.Equals(t, s, "")
+    s, err = RenderView("broken", "mail", "Lulu", nil)
+    assert.True(t, err != nil)
+    assert.True(t, strings.Contains(err.Error(), "broken.html"))
+    assert.Equals(t, s, "")
+}
+
+func TestGlobalViewDataConcurrent(t *testing.T) {
+    dir := createTestViews(t, map[string]string{
+        "mail/count.html": `{{/* layout: none */}}{{.Globals.Count}}`,
+    })
+    defer os.RemoveAll(dir)
+    te := CreateDefaultTemplateEngine(true)
+    SetViewEngines(CreateDefaultViewEngine(dir, "layout", te.Ext(), true), te)
+    defer SetViewEngines(nil, nil)
+    defer setTestGlobalViewData("Count", 0)()
+
+    var wg sync.WaitGroup
+    for i := 0; i < 10; i++ {
+        wg.Add(2)
+        go func(i int) {
+            defer wg.Done()
+            SetGlobalViewData("Count", i)
+        }(i)
+        go func() {
+            defer wg.Done()
+            _, err := RenderView("count", "mail", nil, nil)
+            assert.Equals(t, err, nil)
+        }()
+    }
+    wg.Wait()
+}
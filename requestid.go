@@ -0,0 +1,60 @@
+package goku
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "strconv"
+    "sync/atomic"
+)
+
+// the header to accept and echo the request id
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+type requestIdContextKey struct{}
+
+var requestIdCounter uint64
+
+// generate a unique id for the request
+func newRequestId() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return hex.EncodeToString(b) + "-" + strconv.FormatUint(atomic.AddUint64(&requestIdCounter, 1), 36)
+}
+
+// the request id from the client must be short and printable,
+// for it will be written to the logs and the response header
+func isValidRequestId(id string) bool {
+    if id == "" || len(id) > 128 {
+        return false
+    }
+    for _, c := range id {
+        if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+            c == '-' || c == '_' || c == '.' || c == ':' || c == '/' || c == '+' || c == '=') {
+            return false
+        }
+    }
+    return true
+}
+
+// ContextWithRequestId returns a copy of c with the request id
+func ContextWithRequestId(c context.Context, requestId string) context.Context {
+    return context.WithValue(c, requestIdContextKey{}, requestId)
+}
+
+// RequestIdFromContext gets the request id from c, "" if not exist.
+// ctx.Request.Context() has the request id, so pass it to the db calls:
+//      db.QueryContext(ctx.Request.Context(), "select * from blog")
+func RequestIdFromContext(c context.Context) string {
+    if c == nil {
+        return ""
+    }
+    id, _ := c.Value(requestIdContextKey{}).(string)
+    return id
+}
+
+// RequestID gets the unique id of the request,
+// it is from the X-Request-ID request header, or generated if not exist
+func (ctx *HttpContext) RequestID() string {
+    return ctx.requestId
+}
@@ -0,0 +1,39 @@
+package goku
+
+import (
+    "net/http/httptest"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestRequestId(t *testing.T) {
+    var requestId, contextRequestId string
+    Controller("requestidtest").
+        Get("index", func(ctx *HttpContext) ActionResulter {
+        requestId = ctx.RequestID()
+        contextRequestId = RequestIdFromContext(ctx.Request.Context())
+        return ctx.Raw("ok")
+    })
+    rh := createTestHandler(&ServerConfig{})
+
+    // generated
+    w := httptest.NewRecorder()
+    rh.ServeHTTP(w, httptest.NewRequest("GET", "/requestidtest/index", nil))
+    assert.NotEquals(t, requestId, "")
+    assert.Equals(t, contextRequestId, requestId)
+    assert.Equals(t, w.Header().Get(REQUEST_ID_HEADER), requestId)
+
+    // accept from the client
+    w = httptest.NewRecorder()
+    req := httptest.NewRequest("GET", "/requestidtest/index", nil)
+    req.Header.Set(REQUEST_ID_HEADER, "lb-2012-abc")
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, requestId, "lb-2012-abc")
+    assert.Equals(t, w.Header().Get(REQUEST_ID_HEADER), "lb-2012-abc")
+
+    // invalid request id
+    req = httptest.NewRequest("GET", "/requestidtest/index", nil)
+    req.Header.Set(REQUEST_ID_HEADER, "<script>")
+    rh.ServeHTTP(httptest.NewRecorder(), req)
+    assert.NotEquals(t, requestId, "<script>")
+}
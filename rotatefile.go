@@ -0,0 +1,158 @@
+package goku
+
+import (
+    "github.com/QLeelulu/goku/utils"
+    "io/ioutil"
+    "os"
+    "path"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// RotateFileWriter is an io.Writer that writes to a file,
+// and rotates the file when the size exceeds MaxSize or the date changed.
+// the rotated file is renamed to {Filename}.{20060102-150405}
+type RotateFileWriter struct {
+    Filename   string
+    MaxSize    int64         // max bytes of a file before rotated, no rotate by size if 0
+    Daily      bool          // rotate the file when the date changed
+    MaxBackups int           // max number of the rotated files to keep, keep all if 0
+    MaxAge     time.Duration // max age of the rotated files to keep, keep all if 0
+
+    mu      sync.Mutex
+    file    *os.File
+    size    int64
+    openDay string // the date the file opened, for daily rotation
+}
+
+// CreateRotateFileWriter opens the file for append,
+// the dir will be created if not exist
+func CreateRotateFileWriter(filename string, maxSize int64) (*RotateFileWriter, error) {
+    rw := &RotateFileWriter{
+        Filename: filename,
+        MaxSize:  maxSize,
+    }
+    rw.mu.Lock()
+    defer rw.mu.Unlock()
+    if err := rw.open(); err != nil {
+        return nil, err
+    }
+    return rw, nil
+}
+
+func (rw *RotateFileWriter) open() error {
+    if err := os.MkdirAll(path.Dir(rw.Filename), 0755); err != nil {
+        return err
+    }
+    f, err := os.OpenFile(rw.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+    if err != nil {
+        return err
+    }
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return err
+    }
+    rw.file = f
+    rw.size = info.Size()
+    // the day of the content in the file
+    rw.openDay = info.ModTime().Format("20060102")
+    if rw.size == 0 {
+        rw.openDay = time.Now().Format("20060102")
+    }
+    return nil
+}
+
+func (rw *RotateFileWriter) Write(p []byte) (n int, err error) {
+    rw.mu.Lock()
+    defer rw.mu.Unlock()
+    if rw.file == nil {
+        if err = rw.open(); err != nil {
+            return
+        }
+    }
+    needRotate := rw.MaxSize > 0 && rw.size > 0 && rw.size+int64(len(p)) > rw.MaxSize
+    if !needRotate && rw.Daily && rw.size > 0 && rw.openDay != time.Now().Format("20060102") {
+        needRotate = true
+    }
+    if needRotate {
+        if err = rw.rotate(); err != nil {
+            return
+        }
+    }
+    n, err = rw.file.Write(p)
+    rw.size += int64(n)
+    return
+}
+
+// Rotate closes the current file, renames it, and opens a new one
+func (rw *RotateFileWriter) Rotate() error {
+    rw.mu.Lock()
+    defer rw.mu.Unlock()
+    return rw.rotate()
+}
+
+func (rw *RotateFileWriter) rotate() error {
+    if rw.file != nil {
+        rw.file.Close()
+        rw.file = nil
+    }
+    backup := rw.Filename + "." + time.Now().Format("20060102-150405")
+    // more than one rotation in a second
+    for i := 1; ; i++ {
+        if ok, _ := utils.FileExists(backup); !ok {
+            break
+        }
+        backup = rw.Filename + "." + time.Now().Format("20060102-150405") + "." + strconv.Itoa(i)
+    }
+    if err := os.Rename(rw.Filename, backup); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    rw.removeOldBackups()
+    return rw.open()
+}
+
+// remove the rotated files by MaxBackups & MaxAge
+func (rw *RotateFileWriter) removeOldBackups() {
+    if rw.MaxBackups <= 0 && rw.MaxAge <= 0 {
+        return
+    }
+    dir, base := path.Split(rw.Filename)
+    if dir == "" {
+        dir = "."
+    }
+    infos, err := ioutil.ReadDir(dir)
+    if err != nil {
+        return
+    }
+    backups := make([]os.FileInfo, 0, len(infos))
+    for _, info := range infos {
+        if !info.IsDir() && strings.HasPrefix(info.Name(), base+".") {
+            backups = append(backups, info)
+        }
+    }
+    // newest first, the names are end with the rotated time
+    sort.Slice(backups, func(i, j int) bool {
+        return backups[i].Name() > backups[j].Name()
+    })
+    for i, info := range backups {
+        if (rw.MaxBackups > 0 && i >= rw.MaxBackups) ||
+            (rw.MaxAge > 0 && time.Since(info.ModTime()) > rw.MaxAge) {
+            os.Remove(path.Join(dir, info.Name()))
+        }
+    }
+}
+
+func (rw *RotateFileWriter) Close() error {
+    rw.mu.Lock()
+    defer rw.mu.Unlock()
+    if rw.file == nil {
+        return nil
+    }
+    err := rw.file.Close()
+    rw.file = nil
+    return err
+}
@@ -0,0 +1,50 @@
+package goku
+
+import (
+    "io/ioutil"
+    "os"
+    "path"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestRotateFileWriter(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-log")
+    defer os.RemoveAll(dir)
+    w, err := CreateRotateFileWriter(path.Join(dir, "logs", "access.log"), 10)
+    assert.Equals(t, err, nil)
+    w.Write([]byte("12345678\n"))
+    w.Write([]byte("abc\n"))
+    w.Close()
+    files, _ := ioutil.ReadDir(path.Join(dir, "logs"))
+    assert.Equals(t, len(files), 2)
+    data, _ := ioutil.ReadFile(path.Join(dir, "logs", "access.log"))
+    assert.Equals(t, strings.TrimSpace(string(data)), "abc")
+}
+
+func TestRotateFileWriterRetention(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-log")
+    defer os.RemoveAll(dir)
+    filename := path.Join(dir, "app.log")
+    w, _ := CreateRotateFileWriter(filename, 0)
+    w.MaxBackups = 2
+    for i := 0; i < 4; i++ {
+        w.Write([]byte("line\n"))
+        w.Rotate()
+    }
+    w.Close()
+    files, _ := ioutil.ReadDir(dir)
+    // app.log and 2 backups
+    assert.Equals(t, len(files), 3)
+
+    // date changed
+    w, _ = CreateRotateFileWriter(filename, 0)
+    w.Daily = true
+    w.Write([]byte("line\n"))
+    w.openDay = "20121001"
+    w.Write([]byte("new day\n"))
+    w.Close()
+    data, _ := ioutil.ReadFile(filename)
+    assert.Equals(t, string(data), "new day\n")
+}
@@ -6,6 +6,7 @@ package goku
 import (
     "fmt"
     "regexp"
+    "time"
     //"path"
     "github.com/QLeelulu/goku/utils"
 )
@@ -32,6 +33,7 @@ type Route struct {
     Default    map[string]string // default value for Pattern
     Constraint map[string]string // constraint for Pattern, value is regexp str
     IsStatic   bool              // whether the route is for static file
+    Timeout    time.Duration     // the request's context will be canceled after this, no timeout if 0
 
     rePath *regexp.Regexp
     inited bool
@@ -0,0 +1,161 @@
+package goku
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// the placeholder in ContentSecurityPolicy,
+// will be replaced by the per-request nonce
+const CSP_NONCE_PLACEHOLDER = "{nonce}"
+
+// the key of the csp nonce in ctx.ViewData & ctx.Data,
+// use it in template like this:
+//      <script nonce="{{.Data.CspNonce}}">...</script>
+const CSP_NONCE_KEY = "CspNonce"
+
+// SecurityHeadersOptions is the config for the security headers.
+// empty value means not send the header.
+type SecurityHeadersOptions struct {
+    // Strict-Transport-Security max-age, only send on https request
+    HSTSMaxAge            time.Duration
+    HSTSIncludeSubDomains bool
+    HSTSPreload           bool
+    // X-Frame-Options, "DENY" or "SAMEORIGIN"
+    FrameOptions string
+    // X-Content-Type-Options: nosniff
+    ContentTypeNosniff bool
+    // Referrer-Policy, e.g. "strict-origin-when-cross-origin"
+    ReferrerPolicy string
+    // Content-Security-Policy,
+    // "{nonce}" in it will be replaced by a per-request nonce, e.g.
+    //      "script-src 'self' 'nonce-{nonce}'"
+    ContentSecurityPolicy string
+    // use Content-Security-Policy-Report-Only instead
+    CSPReportOnly bool
+}
+
+// DefaultSecurityHeadersOptions returns the default options:
+//      + Strict-Transport-Security: max-age=31536000; includeSubDomains
+//      + X-Frame-Options: SAMEORIGIN
+//      + X-Content-Type-Options: nosniff
+//      + Referrer-Policy: strict-origin-when-cross-origin
+//      + Content-Security-Policy: default-src 'self'; script-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'
+func DefaultSecurityHeadersOptions() *SecurityHeadersOptions {
+    return &SecurityHeadersOptions{
+        HSTSMaxAge:            365 * 24 * time.Hour,
+        HSTSIncludeSubDomains: true,
+        FrameOptions:          "SAMEORIGIN",
+        ContentTypeNosniff:    true,
+        ReferrerPolicy:        "strict-origin-when-cross-origin",
+        ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'",
+    }
+}
+
+// write the headers to the response,
+// the headers that not set in the options will be removed
+func (opt *SecurityHeadersOptions) apply(ctx *HttpContext) {
+    h := ctx.Header()
+    if opt.HSTSMaxAge > 0 && isHttps(ctx) {
+        v := fmt.Sprintf("max-age=%d", int64(opt.HSTSMaxAge/time.Second))
+        if opt.HSTSIncludeSubDomains {
+            v += "; includeSubDomains"
+        }
+        if opt.HSTSPreload {
+            v += "; preload"
+        }
+        h.Set("Strict-Transport-Security", v)
+    } else {
+        h.Del("Strict-Transport-Security")
+    }
+    setOrDelHeader(ctx, "X-Frame-Options", opt.FrameOptions)
+    if opt.ContentTypeNosniff {
+        h.Set("X-Content-Type-Options", "nosniff")
+    } else {
+        h.Del("X-Content-Type-Options")
+    }
+    setOrDelHeader(ctx, "Referrer-Policy", opt.ReferrerPolicy)
+
+    csp := opt.ContentSecurityPolicy
+    if strings.Contains(csp, CSP_NONCE_PLACEHOLDER) {
+        csp = strings.Replace(csp, CSP_NONCE_PLACEHOLDER, CspNonce(ctx), -1)
+    }
+    h.Del("Content-Security-Policy")
+    h.Del("Content-Security-Policy-Report-Only")
+    if opt.CSPReportOnly {
+        setOrDelHeader(ctx, "Content-Security-Policy-Report-Only", csp)
+    } else {
+        setOrDelHeader(ctx, "Content-Security-Policy", csp)
+    }
+}
+
+func setOrDelHeader(ctx *HttpContext, key, value string) {
+    if value == "" {
+        ctx.Header().Del(key)
+    } else {
+        ctx.SetHeader(key, value)
+    }
+}
+
+func isHttps(ctx *HttpContext) bool {
+    return ctx.Request.TLS != nil || strings.ToLower(ctx.GetHeader("X-Forwarded-Proto")) == "https"
+}
+
+// CspNonce gets the csp nonce of the request,
+// create one if not exist.
+// the nonce also can get from ctx.ViewData["CspNonce"]
+func CspNonce(ctx *HttpContext) string {
+    if v, ok := ctx.Data[CSP_NONCE_KEY]; ok {
+        return v.(string)
+    }
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        panic("CspNonce: can not generate nonce, " + err.Error())
+    }
+    nonce := base64.StdEncoding.EncodeToString(b)
+    ctx.Data[CSP_NONCE_KEY] = nonce
+    ctx.ViewData[CSP_NONCE_KEY] = nonce
+    return nonce
+}
+
+// SecurityHeadersMiddleware adds the security headers to all the response
+type SecurityHeadersMiddleware struct {
+    BaseMiddleware
+    Options *SecurityHeadersOptions
+}
+
+// CreateSecurityHeadersMiddleware creates a security headers middleware,
+// if opt is nil, use DefaultSecurityHeadersOptions()
+func CreateSecurityHeadersMiddleware(opt *SecurityHeadersOptions) *SecurityHeadersMiddleware {
+    if opt == nil {
+        opt = DefaultSecurityHeadersOptions()
+    }
+    return &SecurityHeadersMiddleware{Options: opt}
+}
+
+func (sm *SecurityHeadersMiddleware) OnBeginRequest(ctx *HttpContext) (ActionResulter, error) {
+    sm.Options.apply(ctx)
+    return nil, nil
+}
+
+// SecurityHeadersFilter overrides the security headers
+// for a controller or an action, e.g.
+//      opt := goku.DefaultSecurityHeadersOptions()
+//      opt.FrameOptions = "" // allow the page to be framed
+//      goku.Controller("widget").
+//          Get("embed", embedHandler).
+//          Filters(&goku.SecurityHeadersFilter{Options: opt})
+type SecurityHeadersFilter struct {
+    BaseFilter
+    Options *SecurityHeadersOptions
+}
+
+func (sf *SecurityHeadersFilter) OnActionExecuting(ctx *HttpContext) (ActionResulter, error) {
+    if sf.Options != nil {
+        sf.Options.apply(ctx)
+    }
+    return nil, nil
+}
@@ -0,0 +1,35 @@
+package goku
+
+import (
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+    sm := CreateSecurityHeadersMiddleware(nil)
+    ctx, w := createTestContext("GET", "/", map[string]string{"X-Forwarded-Proto": "https"})
+    sm.OnBeginRequest(ctx)
+    assert.Equals(t, w.Header().Get("Strict-Transport-Security"), "max-age=31536000; includeSubDomains")
+    assert.Equals(t, w.Header().Get("X-Frame-Options"), "SAMEORIGIN")
+    assert.Equals(t, w.Header().Get("X-Content-Type-Options"), "nosniff")
+
+    nonce := ctx.ViewData[CSP_NONCE_KEY].(string)
+    assert.NotEquals(t, nonce, "")
+    assert.StringContains(t, w.Header().Get("Content-Security-Policy"), "'nonce-"+nonce+"'")
+
+    // override by filter, nonce keep the same
+    opt := DefaultSecurityHeadersOptions()
+    opt.FrameOptions = ""
+    opt.CSPReportOnly = true
+    sf := &SecurityHeadersFilter{Options: opt}
+    sf.OnActionExecuting(ctx)
+    assert.Equals(t, w.Header().Get("X-Frame-Options"), "")
+    assert.Equals(t, w.Header().Get("Content-Security-Policy"), "")
+    assert.Equals(t, strings.Contains(w.Header().Get("Content-Security-Policy-Report-Only"), nonce), true)
+
+    // no hsts for http
+    ctx, w = createTestContext("GET", "/", nil)
+    sm.OnBeginRequest(ctx)
+    assert.Equals(t, w.Header().Get("Strict-Transport-Security"), "")
+}
@@ -2,17 +2,20 @@ package goku
 
 import (
     "bytes"
-    // "errors"
+    "context"
+    "errors"
     "encoding/json"
     "flag"
     "fmt"
     "github.com/QLeelulu/goku/utils"
+    "html/template"
+    "io"
     "log"
     "net/http"
-    "net/http/pprof"
     "os"
     "path"
-    "runtime/debug"
+    "sort"
+    texttemplate "text/template"
     "time"
 )
 
@@ -23,6 +26,9 @@ type ServerConfig struct {
     WriteTimeout   time.Duration // maximum duration before timing out write of the response
     MaxHeaderBytes int           // maximum size of request headers, DefaultMaxHeaderBytes if 0
 
+    MaxBodyBytes    int64 // maximum size of request body, no limit if 0, can be overrode by controller or action
+    MultipartMemory int64 // maximum bytes of multipart form stored in memory, the rest stored in temp files, 32MB if 0
+
     RootDir    string // project root dir
     StaticPath string // static file dir, "static" if empty
     ViewPath   string // view file dir, "views" if empty
@@ -30,9 +36,52 @@ type ServerConfig struct {
 
     ViewEnginer     ViewEnginer
     TemplateEnginer TemplateEnginer
+    // the other template engines keyed by the file ext, e.g. ".txt",
+    // the views are rendered by the engine of their ext. see MultiTemplateEngine
+    TemplateEngines map[string]TemplateEnginer
+    // the funcs can be used in the templates of DefaultTemplateEngine & TextTemplateEngine,
+    // with the builtin funcs, e.g. date, truncate & asset
+    TemplateFuncs template.FuncMap
+    // the url of StaticPath, for the asset template func, "/"+StaticPath if empty
+    StaticUrl string
+
+    Logger    *log.Logger
+    LogLevel  int
+    LogFormat string     // "text", "json" or "logfmt", "text" if empty
+    LogSinks  []*LogSink // if set, all the logs write to these sinks, Logger & LogFormat are ignored
+
+    AccessLogger *AccessLogger // the access log, write to Logger() if nil
+
+    // the path to expose the metrics in the Prometheus text format, e.g. "/metrics",
+    // the metrics are not recorded if empty
+    MetricsPath string
+
+    // the prefix of the debug endpoints, pprof, routes, config etc., e.g. "/_debug",
+    // disabled if empty. see DebugHandler
+    DebugPath string
+    // who can visit the debug endpoints, only the loopback requests if nil
+    DebugAuth func(r *http.Request) bool
+
+    // make the error responses of ctx.Error, ctx.NotFound etc. when not in debug mode
+    ErrorHandlers *ErrorHandlers
+    // make the response of the panics, DefaultPanicHandler if nil
+    PanicHandler PanicHandler
+    // called for every panic, e.g. report it to an error tracker
+    PanicHooks []PanicHook
+
+    // trace the requests, see Tracer. not change the tracer if nil
+    Tracer *Tracer
+
+    // the paths of the liveness & readiness checks, e.g. "/healthz" & "/readyz",
+    // disabled if empty. the checks are in DefaultHealthRegistry
+    HealthPath string
+    ReadyPath  string
+    // how long Server.Shutdown waits after the readiness fails
+    ShutdownDelay time.Duration
 
-    Logger   *log.Logger
-    LogLevel int
+    // watch the views & static files in debug mode,
+    // reload the browsers when they changed. see LiveReloader
+    LiveReload bool
 
     Debug bool
 }
@@ -49,55 +98,70 @@ type RequestHandler struct {
     ServerConfig      *ServerConfig
     ViewEnginer       ViewEnginer
     TemplateEnginer   TemplateEnginer
+    AccessLogger      *AccessLogger
+
+    debugHandler *DebugHandler
+    liveReloader *LiveReloader
 }
 
 // implement the http.Handler interface
 // the main entrance of the request handler
 func (rh *RequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-    // if rh.pprofHTTP(w, r) {
-    //     return
-    // }
+    if rh.debugHandler != nil && rh.debugHandler.Match(r.URL.Path) {
+        rh.debugHandler.ServeHTTP(w, r)
+        return
+    }
+    // the probes skip the middlewares, so they need no auth
+    if hp := rh.ServerConfig.HealthPath; hp != "" && r.URL.Path == hp {
+        DefaultHealthRegistry.Handler(false).ServeHTTP(w, r)
+        return
+    }
+    if rp := rh.ServerConfig.ReadyPath; rp != "" && r.URL.Path == rp {
+        DefaultHealthRegistry.Handler(true).ServeHTTP(w, r)
+        return
+    }
+    if rh.liveReloader != nil && r.URL.Path == LIVE_RELOAD_PATH {
+        rh.liveReloader.ServeHTTP(w, r)
+        return
+    }
     var ctx *HttpContext
     ctx = rh.buildContext(w, r)
+    ctx.startRequestSpan()
+    if isMetricsEnabled() {
+        metricRequestsInFlight.Inc()
+        defer metricRequestsInFlight.Dec()
+    }
     var (
         ar  ActionResulter
         err error
     )
     ar, err = rh.execute(ctx)
     if err != nil {
-        ar = ctx.Error(err)
+        er := errorResultOf(err)
+        if er.StatusCode >= 500 {
+            ctx.Logger().Error("request error", "error", err)
+        }
+        ar = er
     }
     if ar != nil {
         ar.ExecuteResult(ctx)
     }
+    if rh.liveReloader != nil {
+        rh.liveReloader.inject(ctx)
+    }
     // response content was cached,
     // flush all the cached content to responsewriter
     ctx.flushToResponse()
-    logRequestInfo(ctx)
-}
-
-func (rh *RequestHandler) pprofHTTP(w http.ResponseWriter, r *http.Request) bool {
-    if !rh.ServerConfig.Debug {
-        return false
-    }
-    switch r.RequestURI {
-    case "/debug/pprof/cmdline":
-        pprof.Cmdline(w, r)
-        return true
-    case "/debug/pprof/profile":
-        pprof.Profile(w, r)
-        return true
-    case "/debug/pprof/heap":
-        h := pprof.Handler("heap")
-        h.ServeHTTP(w, r)
-        return true
-    case "/debug/pprof/symbol":
-        pprof.Symbol(w, r)
-        return true
-    default:
-        return false
-    }
-    return false
+    ctx.endRequestSpan()
+    // remove the temp files of the multipart form,
+    // parsed by ctx.Request, not r, which is replaced by buildContext
+    if ctx.Request.MultipartForm != nil {
+        ctx.Request.MultipartForm.RemoveAll()
+    }
+    rh.accessLog(ctx)
+    if isMetricsEnabled() {
+        recordRequestMetrics(ctx)
+    }
 }
 
 // you can cancel the request three way: 
@@ -107,42 +171,24 @@ func (rh *RequestHandler) pprofHTTP(w http.ResponseWriter, r *http.Request) bool
 func (rh *RequestHandler) execute(ctx *HttpContext) (ar ActionResulter, err error) {
     defer func() {
         // handle all the error
-        err_ := recover()
-        if err_ == nil {
-            return
-        }
-
-        var stack string
-        if Logger().LogLevel() >= LOG_LEVEL_ERROR || rh.ServerConfig.Debug {
-            var buf bytes.Buffer
-            buf.Write(debug.Stack())
-            stack = buf.String()
-        }
-
-        var der *devErrorResult
-        if ctx.requestHandler.ServerConfig.Debug {
-            der = &devErrorResult{
-                StatusCode: 500,
-                Err:        fmt.Sprintf("%v", err_),
-                ShowDetail: rh.ServerConfig.Debug, // if debug enable, show detail
-                Stack:      stack,
-            }
-            ar = der
-            err = nil
-        } else {
-            ar = ctx.Error("Internal Server Error")
-        }
-        if Logger().LogLevel() >= LOG_LEVEL_ERROR || (der != nil && der.ShowDetail) {
-            Logger().Errorln(fmt.Sprintf("%v", err_), "\n", stack)
+        if err_ := recover(); err_ != nil {
+            ar, err = rh.recoverPanic(ctx, err_), nil
         }
-        return
     }()
 
     // being request
+    span := ctx.startSpan("middleware.BeginRequest")
     ar, err = rh.MiddlewareHandler.BeginRequest(ctx)
+    finishSpan(span, err)
     if ctx.Canceled || err != nil || ar != nil {
         return
     }
+    // metrics route, after the begin request middlewares,
+    // so they can protect it, e.g. check the ip
+    if mp := rh.ServerConfig.MetricsPath; mp != "" && ctx.Request.URL.Path == mp {
+        ar = &metricsResult{Registry: DefaultMetricsRegistry}
+        return
+    }
     // match route
     routeData, ok := rh.RouteTable.Match(ctx.Request.URL.Path)
     if !ok {
@@ -150,6 +196,12 @@ func (rh *RequestHandler) execute(ctx *HttpContext) (ar ActionResulter, err erro
         return
     }
     ctx.RouteData = routeData
+    // per-route timeout
+    if routeData.Route.Timeout > 0 {
+        c, cancel := context.WithTimeout(ctx.Context(), routeData.Route.Timeout)
+        defer cancel()
+        ctx.Request = ctx.Request.WithContext(c)
+    }
     // static file route
     // return ContentResult
     if routeData.Route.IsStatic {
@@ -161,10 +213,20 @@ func (rh *RequestHandler) execute(ctx *HttpContext) (ar ActionResulter, err erro
         }
         ar.ExecuteResult(ctx)
     } else {
-        // parse form data before mvc handle
-        ctx.Request.ParseForm()
+        // limit the request body size
+        if ar = rh.limitRequestBody(ctx); ar != nil {
+            return
+        }
+        // parse form data before mvc handle,
+        // multipart form will be parsed lazily by ctx.Get or ctx.FormFile
+        if err_ := ctx.Request.ParseForm(); err_ != nil && isBodyTooLarge(err_) {
+            ar = requestEntityTooLarge(ctx)
+            return
+        }
         // begin mvc handle
+        span = ctx.startSpan("middleware.BeginMvcHandle")
         ar, err = rh.MiddlewareHandler.BeginMvcHandle(ctx)
+        finishSpan(span, err)
         if ctx.Canceled || err != nil || ar != nil {
             return
         }
@@ -174,13 +236,17 @@ func (rh *RequestHandler) execute(ctx *HttpContext) (ar ActionResulter, err erro
             return
         }
         // end mvc handle
+        span = ctx.startSpan("middleware.EndMvcHandle")
         ar, err = rh.MiddlewareHandler.EndMvcHandle(ctx)
+        finishSpan(span, err)
         if ctx.Canceled || err != nil || ar != nil {
             return
         }
     }
     // end request
+    span = ctx.startSpan("middleware.EndRequest")
     ar, err = rh.MiddlewareHandler.EndRequest(ctx)
+    finishSpan(span, err)
     return
 }
 
@@ -193,69 +259,152 @@ func (rh *RequestHandler) executeController(ctx *HttpContext, controller, action
             ctx.Method, controller, action))
         return
     }
+    ctx.actionMatched = true
     // ing & ed filter's order is not the same
     ingFilters := append(ai.Controller.Filters, ai.Filters...)
     // action executing filter
+    span := ctx.startSpan("filter.OnActionExecuting")
     ar, err = runFilterActionExecuting(ctx, ingFilters)
+    finishSpan(span, err)
     if ctx.Canceled || err != nil || ar != nil {
         return
     }
     // execute action
     var rar ActionResulter
-    rar = ai.Handler(ctx)
+    if span = ctx.startSpan("action"); span != nil {
+        span.SetAttribute("goku.controller", controller)
+        span.SetAttribute("goku.action", action)
+        // the spans in the action, e.g. sql, are children of the action span
+        ctx.Request = ctx.Request.WithContext(ContextWithSpan(ctx.Context(), span))
+    }
+    if ai.HandlerWithError != nil {
+        rar, err = ai.HandlerWithError(ctx)
+    } else {
+        rar = ai.Handler(ctx)
+    }
+    finishSpan(span, err)
+    // the request is timeout or the client disconnected
+    if err_ := ctx.Context().Err(); err_ != nil {
+        err = nil
+        if err_ == context.DeadlineExceeded {
+            ar = &ActionResult{
+                StatusCode: http.StatusServiceUnavailable,
+                Headers:    map[string]string{"Content-Type": "text/plain"},
+                Body:       bytes.NewBufferString("Service Unavailable: request timeout"),
+            }
+        } else {
+            ctx.Canceled = true
+        }
+        return
+    }
+    if err != nil {
+        return
+    }
     // action executed filter
     edFilters := append(ai.Filters, ai.Controller.Filters...)
+    span = ctx.startSpan("filter.OnActionExecuted")
     ar, err = runFilterActionExecuted(ctx, edFilters)
+    finishSpan(span, err)
     if ctx.Canceled || err != nil || ar != nil {
         return
     }
     // resule executing filter
+    span = ctx.startSpan("filter.OnResultExecuting")
     ar, err = runFilterResultExecuting(ctx, ingFilters)
+    finishSpan(span, err)
     if ctx.Canceled || err != nil || ar != nil {
         return
     }
     // execute action result
+    if span = ctx.startSpan("result"); span != nil {
+        span.SetAttribute("goku.result", fmt.Sprintf("%T", rar))
+        ctx.Request = ctx.Request.WithContext(ContextWithSpan(ctx.Context(), span))
+    }
     rar.ExecuteResult(ctx)
+    span.End()
     // result executed filter
+    span = ctx.startSpan("filter.OnResultExecuted")
     ar, err = runFilterResultExecuted(ctx, edFilters)
+    finishSpan(span, err)
     return
 }
 
+// limitRequestBody limits the request body size by MaxBodyBytes
+// of the action, controller or ServerConfig, in that order.
+// returns an ActionResulter if the Content-Length is too large
+func (rh *RequestHandler) limitRequestBody(ctx *HttpContext) ActionResulter {
+    limit := rh.ServerConfig.MaxBodyBytes
+    ai := defaultControllerFactory.GetAction(ctx.Method, ctx.RouteData.Controller, ctx.RouteData.Action)
+    if ai != nil {
+        if ai.MaxBodyBytes != 0 {
+            limit = ai.MaxBodyBytes
+        } else if ai.Controller.MaxBodyBytes != 0 {
+            limit = ai.Controller.MaxBodyBytes
+        }
+    }
+    if limit <= 0 || ctx.Request.Body == nil {
+        return nil
+    }
+    if ctx.Request.ContentLength > limit {
+        return requestEntityTooLarge(ctx)
+    }
+    // the ResponseWriter of net/http, so MaxBytesReader
+    // can tell the server to close the connection after the body too large
+    w := ctx.responseWriter
+    for {
+        uw, ok := w.(interface{ Unwrap() http.ResponseWriter })
+        if !ok {
+            break
+        }
+        w = uw.Unwrap()
+    }
+    ctx.Request.Body = http.MaxBytesReader(w, ctx.Request.Body, limit)
+    return nil
+}
+
+func isBodyTooLarge(err error) bool {
+    var mbe *http.MaxBytesError
+    return errors.As(err, &mbe)
+}
+
+func requestEntityTooLarge(ctx *HttpContext) ActionResulter {
+    return &ActionResult{
+        StatusCode: http.StatusRequestEntityTooLarge,
+        Headers:    map[string]string{"Content-Type": "text/plain", "Connection": "close"},
+        Body:       bytes.NewBufferString("Request Entity Too Large"),
+    }
+}
+
 func (rh *RequestHandler) buildContext(w http.ResponseWriter, r *http.Request) *HttpContext {
     //r.ParseForm()
+    // accept the request id from the client or the proxy,
+    // and pass it through the request's context
+    requestId := r.Header.Get(REQUEST_ID_HEADER)
+    if !isValidRequestId(requestId) {
+        requestId = newRequestId()
+    }
+    r = r.WithContext(ContextWithRequestId(r.Context(), requestId))
+    w.Header().Set(REQUEST_ID_HEADER, requestId)
     return &HttpContext{
         Request:              r,
-        responseWriter:       w,
+        responseWriter:       &responseWriter{ResponseWriter: w},
         Method:               r.Method,
         requestHandler:       rh,
         ViewData:             make(map[string]interface{}),
         Data:                 make(map[string]interface{}),
         responseContentCache: new(bytes.Buffer),
+        requestId:            requestId,
+        startTime:            time.Now(),
         //responseHeaderCache: make(map[string]string),
     }
 }
 
-func logRequestInfo(ctx *HttpContext) {
-    if Logger().LogLevel() < LOG_LEVEL_LOG {
-        return
-    }
-    status := 200
-    if ctx.responseStatusCode > 0 {
-        status = ctx.responseStatusCode
-    }
-    routeInfo := ""
-    // N: Unknown
-    // D: Dynamic request
-    // S: Static file
-    handleType := "N"
-    if ctx.RouteData != nil {
-        handleType = "D"
-        if ctx.RouteData.Route.IsStatic {
-            handleType = "S"
-        }
-        //routeInfo = fmt.Sprintf(" >>[n:%v, p:%v]", ctx.RouteData.Route.Name, ctx.RouteData.Route.Pattern)
+func (rh *RequestHandler) accessLog(ctx *HttpContext) {
+    if rh.AccessLogger != nil {
+        rh.AccessLogger.Log(ctx)
+    } else {
+        defaultAccessLogger.Log(ctx)
     }
-    Logger().Logln(handleType, status, ctx.Request.Method, ctx.Request.RequestURI, routeInfo)
 }
 
 // func (rh *RequestHandler) checkError(ctx *HttpContext, ar ActionResulter, err error) ActionResulter {
@@ -293,6 +442,26 @@ func CreateServer(routeTable *RouteTable, middlewares []Middlewarer, sc *ServerC
         _log.Logger = log.New(os.Stdout, "", log.LstdFlags)
     }
     SetLogger(_log)
+    out := io.Writer(os.Stdout)
+    if sc.Logger != nil {
+        out = sc.Logger.Writer()
+    }
+    var sl StructLogger
+    if len(sc.LogSinks) > 0 {
+        sl = CreateFanoutLogger(sc.LogSinks...)
+    } else {
+        sl = CreateStructLogger(out, sc.LogLevel, CreateLogEncoder(sc.LogFormat))
+    }
+    SetStructLogger(sl)
+    if len(sc.LogSinks) > 0 || (sc.LogFormat != "" && sc.LogFormat != "text") {
+        // all the logs are structured
+        SetLogger(&StructLoggerAdapter{StructLogger: sl})
+    }
+
+    EnableMetrics(sc.MetricsPath != "")
+    if sc.Tracer != nil {
+        SetTracer(sc.Tracer)
+    }
 
     mh := &DefaultMiddlewareHandle{
         Middlewares: middlewares,
@@ -303,26 +472,84 @@ func CreateServer(routeTable *RouteTable, middlewares []Middlewarer, sc *ServerC
         MiddlewareHandler: mh,
         ServerConfig:      sc,
         ViewEnginer:       sc.ViewEnginer,
+        TemplateEnginer:   sc.TemplateEnginer,
+        AccessLogger:      sc.AccessLogger,
     }
     if sc.ViewPath == "" {
         sc.ViewPath = "views"
     }
 
+    // the live reloader invalidates the changed templates,
+    // so the cache can be used in debug mode too
+    liveReload := sc.Debug && sc.LiveReload
+    useCache := !sc.Debug || liveReload
+
     // default template engine
     if handler.TemplateEnginer == nil {
         handler.TemplateEnginer = CreateDefaultTemplateEngine(
-            !sc.Debug, // cache template
+            useCache, // cache template
         )
     }
 
+    // the other template engines keyed by the file ext
+    engines := []TemplateEnginer{handler.TemplateEnginer}
+    if len(sc.TemplateEngines) > 0 {
+        mte := CreateMultiTemplateEngine(handler.TemplateEnginer)
+        exts := make([]string, 0, len(sc.TemplateEngines))
+        for ext := range sc.TemplateEngines {
+            exts = append(exts, ext)
+        }
+        sort.Strings(exts)
+        for _, ext := range exts {
+            mte.Register(ext, sc.TemplateEngines[ext])
+            engines = append(engines, sc.TemplateEngines[ext])
+        }
+        handler.TemplateEnginer = mte
+    }
+
+    staticPath, staticUrl := sc.StaticPath, sc.StaticUrl
+    if staticPath == "" {
+        staticPath = "static"
+    }
+    if staticUrl == "" {
+        staticUrl = "/" + staticPath
+    }
+    asset := AssetFunc(path.Join(sc.RootDir, staticPath), staticUrl, !sc.Debug)
+    for _, te := range engines {
+        switch te := te.(type) {
+        case *DefaultTemplateEngine:
+            te.AddFunc("asset", asset)
+            te.AddFuncs(sc.TemplateFuncs)
+        case *TextTemplateEngine:
+            te.AddFunc("asset", asset)
+            te.AddFuncs(texttemplate.FuncMap(sc.TemplateFuncs))
+        }
+    }
+
     // default view engine
     if handler.ViewEnginer == nil {
-        handler.ViewEnginer = CreateDefaultViewEngine(
+        ve := CreateDefaultViewEngine(
             path.Join(sc.RootDir, sc.ViewPath),
             sc.Layout,
             handler.TemplateEnginer.Ext(),
-            !sc.Debug, // cache template
+            useCache, // cache template
         )
+        if mte, ok := handler.TemplateEnginer.(*MultiTemplateEngine); ok {
+            ve.ExtNames = mte.Exts()[1:]
+        }
+        handler.ViewEnginer = ve
+    }
+    SetViewEngines(handler.ViewEnginer, handler.TemplateEnginer)
+
+    if liveReload {
+        handler.liveReloader = createLiveReloader(handler)
+        handler.liveReloader.Start()
+    }
+
+    // debug endpoints
+    if sc.DebugPath != "" {
+        handler.debugHandler = CreateDebugHandler(sc.DebugPath, handler)
+        handler.debugHandler.Auth = sc.DebugAuth
     }
 
     server := new(Server)
@@ -334,6 +561,32 @@ func CreateServer(routeTable *RouteTable, middlewares []Middlewarer, sc *ServerC
     return server
 }
 
+// Precompile parses all the views at startup to fail fast on the template errors,
+// it works with the default view & template engines only, returns nil for the others:
+//      server := goku.CreateServer(routeTable, middlewares, config)
+//      if err := server.Precompile(); err != nil {
+//          log.Fatalln(err)
+//      }
+func (s *Server) Precompile() error {
+    rh, ok := s.Handler.(*RequestHandler)
+    if !ok {
+        return nil
+    }
+    ve, ok := rh.ViewEnginer.(*DefaultViewEngine)
+    if !ok {
+        return nil
+    }
+    tEngine := rh.TemplateEnginer
+    if mte, ok := tEngine.(*MultiTemplateEngine); ok {
+        tEngine = mte.Engine(ve.ExtName)
+    }
+    te, ok := tEngine.(*DefaultTemplateEngine)
+    if !ok {
+        return nil
+    }
+    return te.Precompile(ve)
+}
+
 // load the server conf. 
 // config file is json format. 
 // like this: 
@@ -344,9 +597,26 @@ func CreateServer(routeTable *RouteTable, middlewares []Middlewarer, sc *ServerC
 //         "WriteTimeout": "20ms",
 //         "MaxHeaderBytes": 110,
 //         "StaticPath": "mystatic",
+//         "StaticUrl": "/assets",
 //         "ViewPath": "myview",
 //         "Layout": "mylayout",
 //         "LogLevel": 3,
+//         "AccessLog": {
+//             "Format": "combined",
+//             "File": "/var/log/goku/access.log",
+//             "MaxSize": 104857600,
+//             "Daily": true,
+//             "MaxBackups": 30,
+//             "SampleRate": 0.1,
+//             "ExcludeStatic": true
+//         },
+//         "MetricsPath": "/metrics",
+//         "DebugPath": "/_debug",
+//         "ErrorViews": { "404": "404", "0": "error" },
+//         "HealthPath": "/healthz",
+//         "ReadyPath": "/readyz",
+//         "ShutdownDelay": "5s",
+//         "LiveReload": true,
 //         "Debug": true
 //     }
 // }
@@ -390,9 +660,18 @@ func loadCmdLineConfFile(sc *ServerConfig, rt *RouteTable) {
         if v, ok := msc["MaxHeaderBytes"]; ok {
             sc.MaxHeaderBytes = int(v.(float64))
         }
+        if v, ok := msc["MaxBodyBytes"]; ok {
+            sc.MaxBodyBytes = int64(v.(float64))
+        }
+        if v, ok := msc["MultipartMemory"]; ok {
+            sc.MultipartMemory = int64(v.(float64))
+        }
         if v, ok := msc["StaticPath"]; ok {
             sc.StaticPath = v.(string)
         }
+        if v, ok := msc["StaticUrl"]; ok {
+            sc.StaticUrl = v.(string)
+        }
         if v, ok := msc["ViewPath"]; ok {
             sc.ViewPath = v.(string)
         }
@@ -402,6 +681,42 @@ func loadCmdLineConfFile(sc *ServerConfig, rt *RouteTable) {
         if v, ok := msc["LogLevel"]; ok {
             sc.LogLevel = int(v.(float64))
         }
+        if v, ok := msc["LogFormat"]; ok {
+            sc.LogFormat = v.(string)
+        }
+        if v, ok := msc["LogSinks"]; ok {
+            sc.LogSinks = loadLogSinksConf(v)
+        }
+        if v, ok := msc["AccessLog"]; ok {
+            sc.AccessLogger = loadAccessLogConf(v)
+        }
+        if v, ok := msc["MetricsPath"]; ok {
+            sc.MetricsPath = v.(string)
+        }
+        if v, ok := msc["DebugPath"]; ok {
+            sc.DebugPath = v.(string)
+        }
+        if v, ok := msc["Tracing"]; ok {
+            sc.Tracer = loadTracingConf(v)
+        }
+        if v, ok := msc["HealthPath"]; ok {
+            sc.HealthPath = v.(string)
+        }
+        if v, ok := msc["ReadyPath"]; ok {
+            sc.ReadyPath = v.(string)
+        }
+        if v, ok := msc["ShutdownDelay"]; ok {
+            sc.ShutdownDelay, err = time.ParseDuration(v.(string))
+            if err != nil {
+                log.Fatalln("conf file error: wrong ShutdownDelay format.")
+            }
+        }
+        if v, ok := msc["ErrorViews"]; ok {
+            sc.ErrorHandlers = loadErrorViewsConf(v, sc.ErrorHandlers)
+        }
+        if v, ok := msc["LiveReload"]; ok {
+            sc.LiveReload = v.(bool)
+        }
         if v, ok := msc["Debug"]; ok {
             sc.Debug = v.(bool)
         }
@@ -425,3 +740,27 @@ func loadCmdLineConfFile(sc *ServerConfig, rt *RouteTable) {
         }
     }
 }
+
+// load the access log conf
+func loadAccessLogConf(v interface{}) *AccessLogger {
+    m, ok := v.(map[string]interface{})
+    if !ok {
+        log.Fatalln("conf file error: wrong AccessLog format.")
+    }
+    var format string
+    if v, ok := m["Format"]; ok {
+        format = v.(string)
+    }
+    var out io.Writer
+    if _, ok := m["File"]; ok {
+        out = loadRotateFileConf(m)
+    }
+    al := CreateAccessLogger(format, out)
+    if v, ok := m["SampleRate"]; ok {
+        al.SampleRate = v.(float64)
+    }
+    if v, ok := m["ExcludeStatic"]; ok {
+        al.ExcludeStatic = v.(bool)
+    }
+    return al
+}
@@ -0,0 +1,338 @@
+package goku
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// StructLogger is the structured, leveled logger.
+// keyvals are the key-value pairs, e.g.
+//      l := goku.GetStructLogger().With("module", "blog")
+//      l.Info("blog created", "id", 3, "title", "hello goku")
+type StructLogger interface {
+    LogLevel() int
+    // With returns a child logger with the fields
+    With(keyvals ...interface{}) StructLogger
+    // Log writes the message if level not larger than the logger's level
+    Log(level int, msg string, keyvals ...interface{})
+    Debug(msg string, keyvals ...interface{}) // LOG_LEVEL_DEBUG
+    Info(msg string, keyvals ...interface{})  // LOG_LEVEL_LOG
+    Warn(msg string, keyvals ...interface{})  // LOG_LEVEL_WARN
+    Error(msg string, keyvals ...interface{}) // LOG_LEVEL_ERROR
+}
+
+// a log record
+type LogEntry struct {
+    Time    time.Time
+    Level   int
+    Message string
+    Fields  []interface{} // key-value pairs
+}
+
+// LogEncoder encodes the log entry to a line
+type LogEncoder interface {
+    Encode(e *LogEntry) []byte
+}
+
+// LevelName gets the name of the log level, e.g. "warn"
+func LevelName(level int) string {
+    switch level {
+    case LOG_LEVEL_ERROR:
+        return "error"
+    case LOG_LEVEL_WARN:
+        return "warn"
+    case LOG_LEVEL_NOTICE:
+        return "notice"
+    case LOG_LEVEL_LOG:
+        return "info"
+    case LOG_LEVEL_DEBUG:
+        return "debug"
+    }
+    return strconv.Itoa(level)
+}
+
+// iterate the key-value pairs,
+// a missing value is set to "!MISSING"
+func eachField(fields []interface{}, fn func(key string, val interface{})) {
+    for i := 0; i < len(fields); i += 2 {
+        key, ok := fields[i].(string)
+        if !ok {
+            key = fmt.Sprint(fields[i])
+        }
+        var val interface{} = "!MISSING"
+        if i+1 < len(fields) {
+            val = fields[i+1]
+        }
+        if err, ok := val.(error); ok {
+            val = err.Error()
+        }
+        fn(key, val)
+    }
+}
+
+// TextLogEncoder encodes the entry as the DefaultLogger's format, e.g.
+//      2012/10/01 08:00:00 [WARN] slow query duration=1.2s
+type TextLogEncoder struct{}
+
+func (te *TextLogEncoder) Encode(e *LogEntry) []byte {
+    var b bytes.Buffer
+    b.WriteString(e.Time.Format("2006/01/02 15:04:05 "))
+    if e.Level != LOG_LEVEL_LOG {
+        b.WriteString("[" + strings.ToUpper(LevelName(e.Level)) + "] ")
+    }
+    b.WriteString(strings.TrimRight(e.Message, "\n"))
+    eachField(e.Fields, func(key string, val interface{}) {
+        b.WriteString(" " + key + "=" + logfmtValue(val))
+    })
+    b.WriteByte('\n')
+    return b.Bytes()
+}
+
+// JSONLogEncoder encodes the entry as a json line, e.g.
+//      {"time":"2012-10-01T08:00:00+08:00","level":"warn","msg":"slow query","duration":"1.2s"}
+type JSONLogEncoder struct{}
+
+func (je *JSONLogEncoder) Encode(e *LogEntry) []byte {
+    var b bytes.Buffer
+    b.WriteString(`{"time":`)
+    writeJsonValue(&b, e.Time.Format(time.RFC3339Nano))
+    b.WriteString(`,"level":`)
+    writeJsonValue(&b, LevelName(e.Level))
+    b.WriteString(`,"msg":`)
+    writeJsonValue(&b, strings.TrimRight(e.Message, "\n"))
+    eachField(e.Fields, func(key string, val interface{}) {
+        b.WriteByte(',')
+        writeJsonValue(&b, key)
+        b.WriteByte(':')
+        writeJsonValue(&b, val)
+    })
+    b.WriteString("}\n")
+    return b.Bytes()
+}
+
+func writeJsonValue(b *bytes.Buffer, val interface{}) {
+    switch v := val.(type) {
+    case time.Duration:
+        val = v.String()
+    case fmt.Stringer:
+        val = v.String()
+    }
+    data, err := json.Marshal(val)
+    if err != nil {
+        data, _ = json.Marshal(fmt.Sprint(val))
+    }
+    b.Write(data)
+}
+
+// LogfmtEncoder encodes the entry as logfmt, e.g.
+//      time=2012-10-01T08:00:00+08:00 level=warn msg="slow query" duration=1.2s
+type LogfmtEncoder struct{}
+
+func (le *LogfmtEncoder) Encode(e *LogEntry) []byte {
+    var b bytes.Buffer
+    b.WriteString("time=" + e.Time.Format(time.RFC3339Nano))
+    b.WriteString(" level=" + LevelName(e.Level))
+    b.WriteString(" msg=" + logfmtValue(strings.TrimRight(e.Message, "\n")))
+    eachField(e.Fields, func(key string, val interface{}) {
+        b.WriteString(" " + key + "=" + logfmtValue(val))
+    })
+    b.WriteByte('\n')
+    return b.Bytes()
+}
+
+func logfmtValue(val interface{}) string {
+    s := fmt.Sprint(val)
+    if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+        return strconv.Quote(s)
+    }
+    return s
+}
+
+// CreateLogEncoder creates the encoder by format name:
+// "json", "logfmt" or "text"(default)
+func CreateLogEncoder(format string) LogEncoder {
+    switch strings.ToLower(format) {
+    case "json":
+        return &JSONLogEncoder{}
+    case "logfmt":
+        return &LogfmtEncoder{}
+    }
+    return &TextLogEncoder{}
+}
+
+// DefaultStructLogger writes the encoded entries to Out
+type DefaultStructLogger struct {
+    Out     io.Writer
+    Encoder LogEncoder
+    Level   int
+
+    fields []interface{}
+    mu     *sync.Mutex
+}
+
+// CreateStructLogger creates a structured logger,
+// if enc is nil, use TextLogEncoder
+func CreateStructLogger(out io.Writer, level int, enc LogEncoder) *DefaultStructLogger {
+    if enc == nil {
+        enc = &TextLogEncoder{}
+    }
+    return &DefaultStructLogger{
+        Out:     out,
+        Encoder: enc,
+        Level:   level,
+        mu:      new(sync.Mutex),
+    }
+}
+
+func (l *DefaultStructLogger) LogLevel() int {
+    return l.Level
+}
+
+func (l *DefaultStructLogger) With(keyvals ...interface{}) StructLogger {
+    fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+    fields = append(fields, l.fields...)
+    fields = append(fields, keyvals...)
+    return &DefaultStructLogger{
+        Out:     l.Out,
+        Encoder: l.Encoder,
+        Level:   l.Level,
+        fields:  fields,
+        mu:      l.mu,
+    }
+}
+
+func (l *DefaultStructLogger) Log(level int, msg string, keyvals ...interface{}) {
+    if level > l.Level {
+        return
+    }
+    e := &LogEntry{
+        Time:    time.Now(),
+        Level:   level,
+        Message: msg,
+        Fields:  l.fields,
+    }
+    if len(keyvals) > 0 {
+        e.Fields = append(append([]interface{}(nil), l.fields...), keyvals...)
+    }
+    line := l.Encoder.Encode(e)
+    l.mu.Lock()
+    l.Out.Write(line)
+    l.mu.Unlock()
+}
+
+func (l *DefaultStructLogger) Debug(msg string, keyvals ...interface{}) {
+    l.Log(LOG_LEVEL_DEBUG, msg, keyvals...)
+}
+
+func (l *DefaultStructLogger) Info(msg string, keyvals ...interface{}) {
+    l.Log(LOG_LEVEL_LOG, msg, keyvals...)
+}
+
+func (l *DefaultStructLogger) Warn(msg string, keyvals ...interface{}) {
+    l.Log(LOG_LEVEL_WARN, msg, keyvals...)
+}
+
+func (l *DefaultStructLogger) Error(msg string, keyvals ...interface{}) {
+    l.Log(LOG_LEVEL_ERROR, msg, keyvals...)
+}
+
+// StructLoggerAdapter adapts a StructLogger to the Loggerer,
+// so the Logger().Logln calls write to the structured logger:
+//      goku.SetLogger(&goku.StructLoggerAdapter{StructLogger: sl})
+type StructLoggerAdapter struct {
+    StructLogger StructLogger
+}
+
+func (a *StructLoggerAdapter) LogLevel() int {
+    return a.StructLogger.LogLevel()
+}
+
+func (a *StructLoggerAdapter) Log(args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_LOG, fmt.Sprint(args...))
+}
+
+func (a *StructLoggerAdapter) Logln(args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_LOG, fmt.Sprintln(args...))
+}
+
+func (a *StructLoggerAdapter) Logf(format string, args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_LOG, fmt.Sprintf(format, args...))
+}
+
+func (a *StructLoggerAdapter) Notice(args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_NOTICE, fmt.Sprint(args...))
+}
+
+func (a *StructLoggerAdapter) Noticeln(args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_NOTICE, fmt.Sprintln(args...))
+}
+
+func (a *StructLoggerAdapter) Noticef(format string, args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_NOTICE, fmt.Sprintf(format, args...))
+}
+
+func (a *StructLoggerAdapter) Warn(args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_WARN, fmt.Sprint(args...))
+}
+
+func (a *StructLoggerAdapter) Warnln(args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_WARN, fmt.Sprintln(args...))
+}
+
+func (a *StructLoggerAdapter) Warnf(format string, args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_WARN, fmt.Sprintf(format, args...))
+}
+
+func (a *StructLoggerAdapter) Error(args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_ERROR, fmt.Sprint(args...))
+}
+
+func (a *StructLoggerAdapter) Errorln(args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_ERROR, fmt.Sprintln(args...))
+}
+
+func (a *StructLoggerAdapter) Errorf(format string, args ...interface{}) {
+    a.StructLogger.Log(LOG_LEVEL_ERROR, fmt.Sprintf(format, args...))
+}
+
+var __structLogger StructLogger = CreateStructLogger(os.Stdout, LOG_LEVEL_LOG, nil)
+
+// GetStructLogger gets the global structured logger
+func GetStructLogger() StructLogger {
+    return __structLogger
+}
+
+func SetStructLogger(l StructLogger) {
+    __structLogger = l
+}
+
+// Logger gets the structured logger for the request,
+// with the fields of request id, method, path and route
+func (ctx *HttpContext) Logger() StructLogger {
+    if ctx.logger == nil || (ctx.RouteData != nil && !ctx.loggerHasRoute) {
+        kv := []interface{}{
+            "request_id", ctx.requestId,
+            "method", ctx.Method,
+            "path", ctx.Request.URL.Path,
+        }
+        if ctx.span != nil {
+            kv = append(kv, "trace_id", ctx.span.TraceId)
+        }
+        if ctx.RouteData != nil {
+            kv = append(kv, "route", ctx.RouteData.Route.Name)
+            if !ctx.RouteData.Route.IsStatic {
+                kv = append(kv, "controller", ctx.RouteData.Controller, "action", ctx.RouteData.Action)
+            }
+            ctx.loggerHasRoute = true
+        }
+        ctx.logger = GetStructLogger().With(kv...)
+    }
+    return ctx.logger
+}
@@ -0,0 +1,64 @@
+package goku
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestStructLoggerJSON(t *testing.T) {
+    out := new(bytes.Buffer)
+    l := CreateStructLogger(out, LOG_LEVEL_WARN, &JSONLogEncoder{})
+    l.With("module", "blog").Warn("save failed", "id", 3, "err", errors.New("timeout"))
+    l.Info("not logged")
+
+    lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+    assert.Equals(t, len(lines), 1)
+    var m map[string]interface{}
+    err := json.Unmarshal([]byte(lines[0]), &m)
+    assert.Equals(t, err, nil)
+    assert.Equals(t, m["level"], "warn")
+    assert.Equals(t, m["msg"], "save failed")
+    assert.Equals(t, m["module"], "blog")
+    assert.Equals(t, m["id"], float64(3))
+    assert.Equals(t, m["err"], "timeout")
+}
+
+func TestStructLoggerLogfmt(t *testing.T) {
+    out := new(bytes.Buffer)
+    l := CreateStructLogger(out, LOG_LEVEL_DEBUG, &LogfmtEncoder{})
+    l.Debug("hello goku", "path", "/blog", "odd")
+    s := out.String()
+    assert.StringContains(t, s, " level=debug msg=\"hello goku\" path=/blog odd=!MISSING\n")
+}
+
+func TestStructLoggerAdapter(t *testing.T) {
+    out := new(bytes.Buffer)
+    var l Loggerer = &StructLoggerAdapter{
+        StructLogger: CreateStructLogger(out, LOG_LEVEL_LOG, &LogfmtEncoder{}),
+    }
+    l.Logln("Server start on", ":8080")
+    l.Errorf("error %d", 500)
+    s := out.String()
+    assert.StringContains(t, s, "level=info msg=\"Server start on :8080\"\n")
+    assert.StringContains(t, s, "level=error msg=\"error 500\"\n")
+}
+
+func TestHttpContextLogger(t *testing.T) {
+    out := new(bytes.Buffer)
+    old := GetStructLogger()
+    SetStructLogger(CreateStructLogger(out, LOG_LEVEL_LOG, &LogfmtEncoder{}))
+    defer SetStructLogger(old)
+
+    ctx, _ := createTestContext("GET", "/blog/show", nil)
+    ctx.Logger().Info("before route")
+    ctx.RouteData = &RouteData{Route: &Route{Name: "default"}, Controller: "blog", Action: "show"}
+    ctx.Logger().Info("after route")
+    lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+    assert.StringContains(t, lines[0], "request_id="+ctx.requestId+" method=GET path=/blog/show")
+    assert.Equals(t, strings.Contains(lines[0], "controller="), false)
+    assert.StringContains(t, lines[1], "route=default controller=blog action=show")
+}
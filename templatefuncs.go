@@ -0,0 +1,210 @@
+package goku
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "html/template"
+    "os"
+    "path"
+    "reflect"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "unicode/utf8"
+)
+
+// the funcs can be used in all the templates of DefaultTemplateEngine:
+//      date       {{date "2006-01-02" .Model.Created}}, the time can be time.Time, *time.Time or unix seconds
+//      truncate   {{truncate 20 .Model.Title}}, "..." is appended if truncated
+//      pluralize  {{.Model.Count}} {{pluralize .Model.Count "comment" "comments"}}
+//      safeHTML   {{safeHTML .Model.Content}}, not escape the trusted content,
+//                 safeJS, safeURL, safeCSS & safeAttr are the same
+//      json       <script>var blog = {{json .Model}};</script>
+//      default    {{default "anonymous" .Model.Author}}, the default value if the value is empty
+//      number     {{number .Model.Views}} => 1,234,567; {{number .Model.Price 2}} => 1,234.50
+//      asset      {{asset "/static/site.css"}} => /static/site.css?v=..., versioned by the modified time
+//      dict       {{template "_item" dict "Blog" .Model "Index" 1}}
+//      list       {{range list "a" "b" "c"}}...{{end}}
+var builtinTemplateFuncs = template.FuncMap{
+    "date":      formatDate,
+    "truncate":  truncate,
+    "pluralize": pluralize,
+    "safeHTML":  func(s string) template.HTML { return template.HTML(s) },
+    "safeJS":    func(s string) template.JS { return template.JS(s) },
+    "safeURL":   func(s string) template.URL { return template.URL(s) },
+    "safeCSS":   func(s string) template.CSS { return template.CSS(s) },
+    "safeAttr":  func(s string) template.HTMLAttr { return template.HTMLAttr(s) },
+    "json":      toJson,
+    "default":   defaultValue,
+    "number":    formatNumber,
+    // no version without the server config, see ServerConfig.StaticUrl
+    "asset": func(urlPath string) string { return urlPath },
+    "dict":  dict,
+    "list":  func(values ...interface{}) []interface{} { return values },
+}
+
+func formatDate(layout string, t interface{}) (string, error) {
+    switch v := t.(type) {
+    case time.Time:
+        return v.Format(layout), nil
+    case *time.Time:
+        if v == nil {
+            return "", nil
+        }
+        return v.Format(layout), nil
+    case int:
+        return time.Unix(int64(v), 0).Format(layout), nil
+    case int64:
+        return time.Unix(v, 0).Format(layout), nil
+    case nil:
+        return "", nil
+    }
+    return "", fmt.Errorf("date: unsupported time type %T", t)
+}
+
+func truncate(length int, s string) string {
+    if utf8.RuneCountInString(s) <= length {
+        return s
+    }
+    runes := []rune(s)
+    return string(runes[:length]) + "..."
+}
+
+func pluralize(count interface{}, singular, plural string) (string, error) {
+    n, err := toFloat(count)
+    if err != nil {
+        return "", err
+    }
+    if n == 1 {
+        return singular, nil
+    }
+    return plural, nil
+}
+
+// the json is safe in the script
+func toJson(v interface{}) (template.JS, error) {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return "", err
+    }
+    return template.JS(b), nil
+}
+
+func defaultValue(def, v interface{}) interface{} {
+    if v == nil {
+        return def
+    }
+    rv := reflect.ValueOf(v)
+    if rv.IsZero() {
+        return def
+    }
+    switch rv.Kind() {
+    case reflect.Slice, reflect.Map:
+        if rv.Len() == 0 {
+            return def
+        }
+    }
+    return v
+}
+
+// format the number with the thousands separators,
+// the precision of the float number is optional
+func formatNumber(v interface{}, precision ...int) (string, error) {
+    f, err := toFloat(v)
+    if err != nil {
+        return "", err
+    }
+    prec := 0
+    if len(precision) > 0 {
+        prec = precision[0]
+    } else if _, ok := v.(float64); ok {
+        prec = -1
+    } else if _, ok := v.(float32); ok {
+        prec = -1
+    }
+    s := strconv.FormatFloat(f, 'f', prec, 64)
+    sign := ""
+    if s[0] == '-' {
+        sign, s = "-", s[1:]
+    }
+    intPart, fracPart := s, ""
+    if i := strings.IndexByte(s, '.'); i >= 0 {
+        intPart, fracPart = s[:i], s[i:]
+    }
+    var b strings.Builder
+    b.WriteString(sign)
+    for i, c := range intPart {
+        if i > 0 && (len(intPart)-i)%3 == 0 {
+            b.WriteByte(',')
+        }
+        b.WriteRune(c)
+    }
+    b.WriteString(fracPart)
+    return b.String(), nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+    rv := reflect.ValueOf(v)
+    switch rv.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return float64(rv.Int()), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return float64(rv.Uint()), nil
+    case reflect.Float32, reflect.Float64:
+        return rv.Float(), nil
+    case reflect.String:
+        return strconv.ParseFloat(rv.String(), 64)
+    }
+    return 0, fmt.Errorf("not a number: %v", v)
+}
+
+func dict(kvs ...interface{}) (map[string]interface{}, error) {
+    if len(kvs)%2 != 0 {
+        return nil, errors.New("dict: odd number of arguments")
+    }
+    m := make(map[string]interface{}, len(kvs)/2)
+    for i := 0; i < len(kvs); i += 2 {
+        key, ok := kvs[i].(string)
+        if !ok {
+            return nil, fmt.Errorf("dict: the key must be string, got %T", kvs[i])
+        }
+        m[key] = kvs[i+1]
+    }
+    return m, nil
+}
+
+// AssetFunc creates the "asset" template func,
+// it appends the modified time of the static file as the version:
+//      {{asset "/static/site.css"}} => /static/site.css?v=1k2x3c4
+// urlPrefix is the url of staticDir, e.g. "/static".
+// the versions are cached if cache is true, it should be false in debug mode
+func AssetFunc(staticDir, urlPrefix string, cache bool) func(urlPath string) string {
+    var versions sync.Map
+    urlPrefix = "/" + strings.Trim(urlPrefix, "/")
+    return func(urlPath string) string {
+        if cache {
+            if v, ok := versions.Load(urlPath); ok {
+                return v.(string)
+            }
+        }
+        p := strings.SplitN(urlPath, "?", 2)[0]
+        if !strings.HasPrefix(p, urlPrefix+"/") {
+            return urlPath
+        }
+        fi, err := os.Stat(path.Join(staticDir, strings.TrimPrefix(p, urlPrefix)))
+        if err != nil {
+            return urlPath
+        }
+        sep := "?"
+        if strings.Contains(urlPath, "?") {
+            sep = "&"
+        }
+        versioned := urlPath + sep + "v=" + strconv.FormatInt(fi.ModTime().Unix(), 36)
+        if cache {
+            versions.Store(urlPath, versioned)
+        }
+        return versioned
+    }
+}
@@ -0,0 +1,77 @@
+package goku
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "path"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func renderTestTemplate(t *testing.T, te *DefaultTemplateEngine, content string, model interface{}) string {
+    dir := createTestViews(t, map[string]string{"test.html": content})
+    defer os.RemoveAll(dir)
+    var b bytes.Buffer
+    if err := te.Render(path.Join(dir, "test.html"), "", &ViewData{Model: model}, &b); err != nil {
+        return err.Error()
+    }
+    return b.String()
+}
+
+func TestBuiltinTemplateFuncs(t *testing.T) {
+    te := CreateDefaultTemplateEngine(false)
+    created := time.Date(2012, 7, 1, 8, 30, 0, 0, time.UTC)
+    model := map[string]interface{}{
+        "Created": created,
+        "Title":   "goku is a web mvc framework",
+        "Count":   1,
+        "Views":   1234567,
+        "Price":   -1234.5,
+        "Html":    "<b>bold</b>",
+        "Author":  "",
+    }
+    render := func(content string) string {
+        return renderTestTemplate(t, te, content, model)
+    }
+
+    assert.Equals(t, render(`{{date "2006-01-02 15:04" .Model.Created}}`), "2012-07-01 08:30")
+    assert.Equals(t, render(`{{truncate 4 .Model.Title}}|{{truncate 100 "short"}}`), "goku...|short")
+    assert.Equals(t, render(`{{pluralize .Model.Count "comment" "comments"}} {{pluralize 2 "comment" "comments"}}`),
+        "comment comments")
+    assert.Equals(t, render(`{{.Model.Html}}|{{safeHTML .Model.Html}}`), "&lt;b&gt;bold&lt;/b&gt;|<b>bold</b>")
+    assert.Equals(t, render(`<script>var m = {{json .Model.Count}};</script>`), "<script>var m = 1;</script>")
+    assert.Equals(t, render(`{{default "anonymous" .Model.Author}} {{default "x" .Model.Count}}`), "anonymous 1")
+    assert.Equals(t, render(`{{number .Model.Views}} {{number .Model.Price 2}} {{number 999}}`), "1,234,567 -1,234.50 999")
+    assert.Equals(t, render(`{{define "item"}}{{.Name}}={{.Value}}{{end}}{{template "item" dict "Name" "a" "Value" 1}}`), "a=1")
+    assert.Equals(t, render(`{{range list "a" "b"}}{{.}}{{end}}`), "ab")
+    assert.Equals(t, render(`{{asset "/static/site.css"}}`), "/static/site.css")
+}
+
+func TestTemplateAddFunc(t *testing.T) {
+    te := CreateDefaultTemplateEngine(true)
+    te.AddFunc("upper", strings.ToUpper)
+    assert.Equals(t, renderTestTemplate(t, te, `{{upper "goku"}}`, nil), "GOKU")
+    // override the builtin func
+    te.AddFunc("truncate", func(n int, s string) string { return s[:n] })
+    assert.Equals(t, renderTestTemplate(t, te, `{{truncate 2 "goku"}}`, nil), "go")
+}
+
+func TestAssetFunc(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-asset")
+    defer os.RemoveAll(dir)
+    os.MkdirAll(path.Join(dir, "css"), 0755)
+    ioutil.WriteFile(path.Join(dir, "css", "site.css"), []byte("body{}"), 0644)
+    modTime := time.Unix(1341100000, 0)
+    os.Chtimes(path.Join(dir, "css", "site.css"), modTime, modTime)
+    v := strconv.FormatInt(modTime.Unix(), 36)
+
+    asset := AssetFunc(dir, "/static/", true)
+    assert.Equals(t, asset("/static/css/site.css"), "/static/css/site.css?v="+v)
+    assert.Equals(t, asset("/static/css/site.css?media=print"), "/static/css/site.css?media=print&v="+v)
+    assert.Equals(t, asset("/static/no.css"), "/static/no.css")
+    assert.Equals(t, asset("/other/site.css"), "/other/site.css")
+}
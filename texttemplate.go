@@ -0,0 +1,141 @@
+package goku
+
+import (
+    "io"
+    "io/ioutil"
+    "sync"
+    "text/template"
+)
+
+// TextTemplateEngine renders the views by text/template, without html escaping,
+// for the non-html outputs, e.g. the plain text email bodies.
+// it supports the layouts, sections, partials & the builtin funcs
+// like DefaultTemplateEngine. register it with the ext name to ServerConfig:
+//      config.TemplateEngines = map[string]goku.TemplateEnginer{
+//          ".txt": goku.CreateTextTemplateEngine(true),
+//      }
+type TextTemplateEngine struct {
+    ExtName  string // ".txt" if empty
+    UseCache bool
+
+    mu       sync.RWMutex // for funcs & cache
+    funcs    template.FuncMap
+    cache    map[string]*template.Template
+    cacheGen int // changed by AddFuncs & Invalidate
+}
+
+func CreateTextTemplateEngine(useCache bool) *TextTemplateEngine {
+    return &TextTemplateEngine{
+        UseCache: useCache,
+        cache:    make(map[string]*template.Template),
+    }
+}
+
+// template file ext name, default is ".txt"
+func (te *TextTemplateEngine) Ext() string {
+    if te.ExtName == "" {
+        return ".txt"
+    }
+    return te.ExtName
+}
+
+func (te *TextTemplateEngine) SupportLayout() bool {
+    return true
+}
+
+// AddFunc adds a func can be used in the templates,
+// override the builtin func with the same name
+func (te *TextTemplateEngine) AddFunc(name string, fn interface{}) {
+    te.AddFuncs(template.FuncMap{name: fn})
+}
+
+// AddFuncs adds the funcs, see AddFunc
+func (te *TextTemplateEngine) AddFuncs(funcs template.FuncMap) {
+    te.mu.Lock()
+    defer te.mu.Unlock()
+    m := make(template.FuncMap, len(te.funcs)+len(funcs))
+    for k, v := range te.funcs {
+        m[k] = v
+    }
+    for k, v := range funcs {
+        m[k] = v
+    }
+    te.funcs = m
+    te.cacheGen++
+    te.cache = make(map[string]*template.Template)
+}
+
+func (te *TextTemplateEngine) Render(filepath string, layoutPath string, viewData *ViewData, wr io.Writer) error {
+    if layoutPath != "" {
+        return te.RenderLayouts(filepath, []string{layoutPath}, viewData, wr)
+    }
+    return te.RenderLayouts(filepath, nil, viewData, wr)
+}
+
+// RenderLayouts renders the view in the nested layouts,
+// see DefaultTemplateEngine.RenderLayouts
+func (te *TextTemplateEngine) RenderLayouts(viewPath string, layoutPaths []string, viewData *ViewData, wr io.Writer) error {
+    filepaths := templateFiles(viewPath, layoutPaths)
+    tmpl, err := te.parse(filepaths)
+    if err != nil {
+        return err
+    }
+    // a clone for each render, the funcs are bound to it
+    if tmpl, err = tmpl.Clone(); err != nil {
+        return &TemplateError{Files: filepaths, Err: err}
+    }
+    return executeLayouts(&layoutTemplate{
+        execute: tmpl.ExecuteTemplate,
+        defined: func(name string) bool { return tmpl.Lookup(name) != nil },
+        body:    func(s string) interface{} { return s },
+        funcs:   func(funcs map[string]interface{}) { tmpl.Funcs(funcs) },
+    }, filepaths, viewData, wr)
+}
+
+func (te *TextTemplateEngine) parse(filepaths []string) (*template.Template, error) {
+    cacheKey := templateCacheKey(filepaths)
+    te.mu.RLock()
+    tmpl, funcs, gen := te.cache[cacheKey], te.funcs, te.cacheGen
+    te.mu.RUnlock()
+    if tmpl != nil {
+        return tmpl, nil
+    }
+    // the text templates are cheap to parse, parse them again if concurrent
+    tmpl = template.New(filepaths[0]).
+        Funcs(template.FuncMap(builtinTemplateFuncs)).
+        Funcs(funcs)
+    for _, file := range filepaths {
+        b, err := ioutil.ReadFile(file)
+        if err == nil {
+            t := tmpl
+            if file != filepaths[0] {
+                t = tmpl.New(file)
+            }
+            _, err = t.Parse(string(b))
+        }
+        if err != nil {
+            return nil, &TemplateError{Files: filepaths, Parse: true, Err: err}
+        }
+    }
+    if te.UseCache {
+        te.mu.Lock()
+        // not cache it if the funcs or the files changed while parsing
+        if gen == te.cacheGen {
+            te.cache[cacheKey] = tmpl
+        }
+        te.mu.Unlock()
+    }
+    return tmpl, nil
+}
+
+// Invalidate drops the parsed templates use the file
+func (te *TextTemplateEngine) Invalidate(file string) {
+    te.mu.Lock()
+    defer te.mu.Unlock()
+    te.cacheGen++
+    for key := range te.cache {
+        if templateCacheKeyHas(key, file) {
+            delete(te.cache, key)
+        }
+    }
+}
@@ -0,0 +1,415 @@
+package goku
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "io"
+    "log"
+    mrand "math/rand"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+// the W3C trace context header
+const TRACEPARENT_HEADER = "traceparent"
+
+// Span is a timed operation of a trace, e.g. the action or a sql query.
+// all the methods are safe to call on a nil span,
+// so the code need not check whether the tracing is enabled
+type Span struct {
+    TraceId      string // 32 hex
+    SpanId       string // 16 hex
+    ParentSpanId string // "" if it is the root span
+    Name         string
+    StartTime    time.Time
+    EndTime      time.Time
+    Attributes   map[string]interface{}
+    Error        string // the error of the operation, "" if ok
+    Sampled      bool   // only the sampled spans are exported
+
+    tracer *Tracer
+    mu     sync.Mutex
+    ended  bool
+}
+
+// SetAttribute sets an attribute of the span, e.g.
+//      span.SetAttribute("db.statement", query)
+func (s *Span) SetAttribute(key string, val interface{}) {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    if s.Attributes == nil {
+        s.Attributes = make(map[string]interface{})
+    }
+    s.Attributes[key] = val
+    s.mu.Unlock()
+}
+
+// SetError marks the span as failed, nothing happen if err is nil
+func (s *Span) SetError(err error) {
+    if s == nil || err == nil {
+        return
+    }
+    s.mu.Lock()
+    s.Error = err.Error()
+    s.mu.Unlock()
+}
+
+// End ends the span and exports it if sampled,
+// only the first call works
+func (s *Span) End() {
+    if s == nil {
+        return
+    }
+    s.mu.Lock()
+    if s.ended {
+        s.mu.Unlock()
+        return
+    }
+    s.ended = true
+    s.EndTime = time.Now()
+    s.mu.Unlock()
+    if s.Sampled && s.tracer.Exporter != nil {
+        s.tracer.Exporter.ExportSpan(s)
+    }
+}
+
+// Duration gets the duration of the ended span
+func (s *Span) Duration() time.Duration {
+    return s.EndTime.Sub(s.StartTime)
+}
+
+// Traceparent gets the W3C traceparent header value of the span,
+// pass it to the downstream services
+func (s *Span) Traceparent() string {
+    if s == nil {
+        return ""
+    }
+    flags := "00"
+    if s.Sampled {
+        flags = "01"
+    }
+    return "00-" + s.TraceId + "-" + s.SpanId + "-" + flags
+}
+
+// SpanExporter receives the ended spans
+type SpanExporter interface {
+    ExportSpan(s *Span)
+}
+
+// Tracer creates the spans. set it to ServerConfig.Tracer, e.g.
+//      exp, err := goku.CreateJSONFileSpanExporter("/var/log/myapp/trace.log")
+//      config.Tracer = goku.CreateTracer(exp)
+type Tracer struct {
+    Exporter SpanExporter
+    // the rate of the new traces to be sampled, between 0 and 1, 0 means all.
+    // the traces from the upstream follow the sampled flag of the traceparent
+    SampleRate float64
+}
+
+func CreateTracer(exporter SpanExporter) *Tracer {
+    return &Tracer{Exporter: exporter}
+}
+
+func (t *Tracer) newSpan(name string, parent *Span) *Span {
+    s := &Span{
+        Name:      name,
+        SpanId:    randomHex(8),
+        StartTime: time.Now(),
+        tracer:    t,
+    }
+    if parent != nil {
+        s.TraceId = parent.TraceId
+        s.ParentSpanId = parent.SpanId
+        s.Sampled = parent.Sampled
+    } else {
+        s.TraceId = randomHex(16)
+        s.Sampled = t.SampleRate <= 0 || t.SampleRate >= 1 || mrand.Float64() < t.SampleRate
+    }
+    return s
+}
+
+// StartSpan starts a span, the span in c is the parent.
+// returns the context with the new span
+func (t *Tracer) StartSpan(c context.Context, name string) (context.Context, *Span) {
+    s := t.newSpan(name, SpanFromContext(c))
+    return ContextWithSpan(c, s), s
+}
+
+var (
+    defaultTracer *Tracer
+    tracerMutex   sync.RWMutex
+)
+
+// GetTracer gets the tracer, nil if the tracing is disabled
+func GetTracer() *Tracer {
+    tracerMutex.RLock()
+    defer tracerMutex.RUnlock()
+    return defaultTracer
+}
+
+// SetTracer sets the tracer, disable the tracing if nil
+func SetTracer(t *Tracer) {
+    tracerMutex.Lock()
+    defaultTracer = t
+    tracerMutex.Unlock()
+}
+
+// StartSpan starts a span by the tracer, the span in c is the parent,
+// returns c and nil span if the tracing is disabled, e.g.
+//      c, span := goku.StartSpan(ctx.Context(), "loadUser")
+//      defer span.End()
+//      span.SetAttribute("user.id", id)
+func StartSpan(c context.Context, name string) (context.Context, *Span) {
+    t := GetTracer()
+    if t == nil {
+        return c, nil
+    }
+    return t.StartSpan(c, name)
+}
+
+// start a span only if there is a parent span in c,
+// e.g. the sql queries outside of a request are not traced
+func startChildSpan(c context.Context, name string) (context.Context, *Span) {
+    t := GetTracer()
+    if t == nil || SpanFromContext(c) == nil {
+        return c, nil
+    }
+    return t.StartSpan(c, name)
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of c with the span
+func ContextWithSpan(c context.Context, s *Span) context.Context {
+    return context.WithValue(c, spanContextKey{}, s)
+}
+
+// SpanFromContext gets the current span in c, nil if not exist
+func SpanFromContext(c context.Context) *Span {
+    if c == nil {
+        return nil
+    }
+    s, _ := c.Value(spanContextKey{}).(*Span)
+    return s
+}
+
+// InjectTraceparent sets the traceparent header by the span in c,
+// for calling the downstream services
+func InjectTraceparent(c context.Context, h http.Header) {
+    if s := SpanFromContext(c); s != nil {
+        h.Set(TRACEPARENT_HEADER, s.Traceparent())
+    }
+}
+
+// ParseTraceparent parses the W3C traceparent header:
+//      00-{trace id, 32 hex}-{parent id, 16 hex}-{flags, 2 hex}
+func ParseTraceparent(v string) (traceId, spanId string, sampled bool, ok bool) {
+    parts := strings.Split(strings.TrimSpace(v), "-")
+    if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+        len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+        return
+    }
+    // version 00 must have exactly 4 parts
+    if parts[0] == "00" && len(parts) != 4 {
+        return
+    }
+    for _, p := range parts[:4] {
+        if !isLowerHex(p) {
+            return
+        }
+    }
+    if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+        return
+    }
+    flags, _ := hex.DecodeString(parts[3])
+    return parts[1], parts[2], flags[0]&1 == 1, true
+}
+
+func isLowerHex(s string) bool {
+    for _, c := range s {
+        if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+            return false
+        }
+    }
+    return true
+}
+
+func randomHex(n int) string {
+    b := make([]byte, n)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
+
+// start the root span of the request,
+// continue the trace if the request has the traceparent header
+func (ctx *HttpContext) startRequestSpan() {
+    t := GetTracer()
+    if t == nil {
+        return
+    }
+    var remote *Span
+    if traceId, spanId, sampled, ok := ParseTraceparent(ctx.Request.Header.Get(TRACEPARENT_HEADER)); ok {
+        remote = &Span{TraceId: traceId, SpanId: spanId, Sampled: sampled}
+    }
+    s := t.newSpan("http.request", remote)
+    s.SetAttribute("http.method", ctx.Method)
+    s.SetAttribute("http.target", ctx.Request.URL.RequestURI())
+    s.SetAttribute("goku.request_id", ctx.requestId)
+    ctx.span = s
+    ctx.Request = ctx.Request.WithContext(ContextWithSpan(ctx.Request.Context(), s))
+}
+
+// end the root span of the request
+func (ctx *HttpContext) endRequestSpan() {
+    s := ctx.span
+    if s == nil {
+        return
+    }
+    if ctx.RouteData != nil {
+        s.SetAttribute("http.route", ctx.RouteData.Route.Name)
+        if !ctx.RouteData.Route.IsStatic {
+            s.SetAttribute("goku.controller", ctx.RouteData.Controller)
+            s.SetAttribute("goku.action", ctx.RouteData.Action)
+        }
+    }
+    status := ctx.responseStatusCode
+    if status == 0 {
+        status = http.StatusOK
+    }
+    s.SetAttribute("http.status_code", status)
+    s.End()
+}
+
+// start a span of a stage of the request, the root span is the parent
+func (ctx *HttpContext) startSpan(name string) *Span {
+    if ctx.span == nil {
+        return nil
+    }
+    return ctx.span.tracer.newSpan(name, ctx.span)
+}
+
+// end the span of a stage, with the error if any
+func finishSpan(s *Span, err error) {
+    s.SetError(err)
+    s.End()
+}
+
+// Span gets the root span of the request, nil if the tracing is disabled.
+// use goku.SpanFromContext(ctx.Context()) for the current span, e.g. in the action
+func (ctx *HttpContext) Span() *Span {
+    return ctx.span
+}
+
+// InMemorySpanExporter keeps the spans in memory, for tests
+type InMemorySpanExporter struct {
+    mu    sync.Mutex
+    spans []*Span
+}
+
+func CreateInMemorySpanExporter() *InMemorySpanExporter {
+    return &InMemorySpanExporter{}
+}
+
+func (e *InMemorySpanExporter) ExportSpan(s *Span) {
+    e.mu.Lock()
+    e.spans = append(e.spans, s)
+    e.mu.Unlock()
+}
+
+// Spans gets the exported spans, in the order they ended
+func (e *InMemorySpanExporter) Spans() []*Span {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    return append([]*Span(nil), e.spans...)
+}
+
+// Reset removes all the spans
+func (e *InMemorySpanExporter) Reset() {
+    e.mu.Lock()
+    e.spans = nil
+    e.mu.Unlock()
+}
+
+// JSONSpanExporter writes a json object per line for every span
+type JSONSpanExporter struct {
+    Out io.Writer
+
+    mu sync.Mutex
+}
+
+func CreateJSONSpanExporter(out io.Writer) *JSONSpanExporter {
+    return &JSONSpanExporter{Out: out}
+}
+
+// CreateJSONFileSpanExporter creates a JSONSpanExporter writes to the file,
+// the file is a RotateFileWriter, set the rotation by exp.Out.(*goku.RotateFileWriter)
+func CreateJSONFileSpanExporter(filename string) (*JSONSpanExporter, error) {
+    w, err := CreateRotateFileWriter(filename, 0)
+    if err != nil {
+        return nil, err
+    }
+    return CreateJSONSpanExporter(w), nil
+}
+
+type jsonSpan struct {
+    TraceId      string                 `json:"trace_id"`
+    SpanId       string                 `json:"span_id"`
+    ParentSpanId string                 `json:"parent_span_id,omitempty"`
+    Name         string                 `json:"name"`
+    StartTime    string                 `json:"start_time"`
+    EndTime      string                 `json:"end_time"`
+    DurationMs   float64                `json:"duration_ms"`
+    Attributes   map[string]interface{} `json:"attributes,omitempty"`
+    Error        string                 `json:"error,omitempty"`
+}
+
+func (e *JSONSpanExporter) ExportSpan(s *Span) {
+    s.mu.Lock()
+    js := &jsonSpan{
+        TraceId:      s.TraceId,
+        SpanId:       s.SpanId,
+        ParentSpanId: s.ParentSpanId,
+        Name:         s.Name,
+        StartTime:    s.StartTime.Format(time.RFC3339Nano),
+        EndTime:      s.EndTime.Format(time.RFC3339Nano),
+        DurationMs:   float64(s.Duration()) / float64(time.Millisecond),
+        Attributes:   s.Attributes,
+        Error:        s.Error,
+    }
+    b, err := json.Marshal(js)
+    s.mu.Unlock()
+    if err != nil {
+        Logger().Errorln("JSONSpanExporter:", err)
+        return
+    }
+    b = append(b, '\n')
+    e.mu.Lock()
+    e.Out.Write(b)
+    e.mu.Unlock()
+}
+
+// load the tracing conf, like this:
+//      "Tracing": {
+//          "File": "/var/log/goku/trace.log",
+//          "SampleRate": 0.1,
+//          "MaxSize": 104857600,
+//          "Daily": true,
+//          "MaxBackups": 30
+//      }
+func loadTracingConf(v interface{}) *Tracer {
+    m, ok := v.(map[string]interface{})
+    if !ok {
+        log.Fatalln("conf file error: wrong Tracing format.")
+    }
+    t := CreateTracer(CreateJSONSpanExporter(loadRotateFileConf(m)))
+    if v, ok := m["SampleRate"]; ok {
+        t.SampleRate = v.(float64)
+    }
+    return t
+}
@@ -0,0 +1,130 @@
+package goku
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+    traceId, spanId, sampled, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+    assert.True(t, ok)
+    assert.Equals(t, traceId, "4bf92f3577b34da6a3ce929d0e0e4736")
+    assert.Equals(t, spanId, "00f067aa0ba902b7")
+    assert.True(t, sampled)
+
+    _, _, sampled, ok = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+    assert.True(t, ok)
+    assert.True(t, !sampled)
+
+    for _, v := range []string{
+        "",
+        "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+        "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+        "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+        "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+        "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+        "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+    } {
+        _, _, _, ok = ParseTraceparent(v)
+        assert.True(t, !ok)
+    }
+}
+
+func TestSpanNil(t *testing.T) {
+    SetTracer(nil)
+    c, span := StartSpan(context.Background(), "noop")
+    assert.True(t, span == nil)
+    assert.Equals(t, c, context.Background())
+    // safe to call on nil span
+    span.SetAttribute("k", "v")
+    span.SetError(errors.New("err"))
+    span.End()
+    assert.Equals(t, span.Traceparent(), "")
+}
+
+func TestRequestTracing(t *testing.T) {
+    exp := CreateInMemorySpanExporter()
+    SetTracer(CreateTracer(exp))
+    defer SetTracer(nil)
+
+    var downstream http.Header
+    Controller("tracetest").
+        Get("index", func(ctx *HttpContext) ActionResulter {
+        _, span := StartSpan(ctx.Context(), "custom")
+        span.SetAttribute("user.id", 1)
+        span.End()
+        startSqlSpan(ctx.Context(), "select * from blog").End()
+        downstream = http.Header{}
+        InjectTraceparent(ctx.Context(), downstream)
+        return ctx.Raw("ok")
+    })
+    rh := createTestHandler(&ServerConfig{})
+
+    w := httptest.NewRecorder()
+    req, _ := http.NewRequest("GET", "/tracetest/index", nil)
+    req.Header.Set(TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Body.String(), "ok")
+
+    spans := make(map[string]*Span)
+    for _, s := range exp.Spans() {
+        spans[s.Name] = s
+        assert.Equals(t, s.TraceId, "4bf92f3577b34da6a3ce929d0e0e4736")
+    }
+    root := spans["http.request"]
+    assert.Equals(t, root.ParentSpanId, "00f067aa0ba902b7")
+    assert.Equals(t, root.Attributes["http.status_code"], 200)
+    assert.Equals(t, root.Attributes["goku.controller"], "tracetest")
+    for _, name := range []string{"middleware.BeginRequest", "middleware.BeginMvcHandle",
+        "filter.OnActionExecuting", "action", "filter.OnActionExecuted",
+        "filter.OnResultExecuting", "result", "filter.OnResultExecuted",
+        "middleware.EndMvcHandle", "middleware.EndRequest"} {
+        s, ok := spans[name]
+        assert.True(t, ok)
+        assert.Equals(t, s.ParentSpanId, root.SpanId)
+    }
+    action := spans["action"]
+    assert.Equals(t, spans["custom"].ParentSpanId, action.SpanId)
+    assert.Equals(t, spans["custom"].Attributes["user.id"], 1)
+    assert.Equals(t, spans["db.select"].ParentSpanId, action.SpanId)
+    assert.Equals(t, spans["db.select"].Attributes["db.statement"], "select * from blog")
+    assert.Equals(t, downstream.Get(TRACEPARENT_HEADER), "00-4bf92f3577b34da6a3ce929d0e0e4736-"+action.SpanId+"-01")
+
+    // not sampled by the upstream
+    exp.Reset()
+    req, _ = http.NewRequest("GET", "/tracetest/index", nil)
+    req.Header.Set(TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+    rh.ServeHTTP(httptest.NewRecorder(), req)
+    assert.Equals(t, len(exp.Spans()), 0)
+
+    // no sql span without a parent
+    assert.True(t, startSqlSpan(context.Background(), "select 1") == nil)
+}
+
+func TestJSONSpanExporter(t *testing.T) {
+    var b bytes.Buffer
+    tracer := CreateTracer(CreateJSONSpanExporter(&b))
+    c, parent := tracer.StartSpan(context.Background(), "parent")
+    _, child := tracer.StartSpan(c, "child")
+    child.SetAttribute("db.statement", "select 1")
+    child.SetError(errors.New("timeout"))
+    child.End()
+    child.End()
+    parent.End()
+
+    lines := bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n"))
+    assert.Equals(t, len(lines), 2)
+    var js map[string]interface{}
+    assert.Equals(t, json.Unmarshal(lines[0], &js), nil)
+    assert.Equals(t, js["name"], "child")
+    assert.Equals(t, js["trace_id"], parent.TraceId)
+    assert.Equals(t, js["parent_span_id"], parent.SpanId)
+    assert.Equals(t, js["error"], "timeout")
+    assert.Equals(t, js["attributes"].(map[string]interface{})["db.statement"], "select 1")
+}
@@ -0,0 +1,39 @@
+package goku
+
+import (
+    "io"
+    "mime/multipart"
+    "os"
+)
+
+// UploadFile is the file uploaded by multipart form,
+// get it by ctx.FormFile(name) or ctx.FormFiles(name)
+type UploadFile struct {
+    FieldName string // the form field name
+    *multipart.FileHeader
+}
+
+// ContentType gets the content type send by the client
+func (uf *UploadFile) ContentType() string {
+    return uf.Header.Get("Content-Type")
+}
+
+// SaveTo saves the uploaded file to filePath,
+// the file will be created or truncated
+func (uf *UploadFile) SaveTo(filePath string) error {
+    src, err := uf.Open()
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+
+    dst, err := os.Create(filePath)
+    if err != nil {
+        return err
+    }
+    _, err = io.Copy(dst, src)
+    if err2 := dst.Close(); err == nil {
+        err = err2
+    }
+    return err
+}
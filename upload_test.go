@@ -0,0 +1,190 @@
+package goku
+
+import (
+    "bytes"
+    "github.com/QLeelulu/goku/form"
+    "io/ioutil"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path"
+    "strings"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+// create a RequestHandler with the default route for test
+func createTestHandler(sc *ServerConfig) *RequestHandler {
+    rt := new(RouteTable)
+    rt.Map("default", "/{controller}/{action}", map[string]string{"controller": "home", "action": "index"})
+    return &RequestHandler{
+        RouteTable:        rt,
+        MiddlewareHandler: &DefaultMiddlewareHandle{},
+        ServerConfig:      sc,
+    }
+}
+
+func createMultipartRequest(url string, fields map[string]string, fileField, fileName, content string) *http.Request {
+    body := new(bytes.Buffer)
+    mw := multipart.NewWriter(body)
+    for k, v := range fields {
+        mw.WriteField(k, v)
+    }
+    fw, _ := mw.CreateFormFile(fileField, fileName)
+    fw.Write([]byte(content))
+    mw.Close()
+    req, _ := http.NewRequest("POST", url, body)
+    req.Header.Set("Content-Type", mw.FormDataContentType())
+    return req
+}
+
+func TestUpload(t *testing.T) {
+    dir, _ := ioutil.TempDir("", "goku-upload")
+    defer os.RemoveAll(dir)
+
+    Controller("uploadtest").
+        Post("save", func(ctx *HttpContext) ActionResulter {
+        f, err := ctx.FormFile("file")
+        if err != nil {
+            return ctx.Error(err)
+        }
+        if err = f.SaveTo(path.Join(dir, f.Filename)); err != nil {
+            return ctx.Error(err)
+        }
+        return ctx.Raw(ctx.Get("title") + ":" + f.Filename)
+    }).
+        Post("title", func(ctx *HttpContext) ActionResulter {
+        title := ctx.Get("title")
+        if err := ctx.FormError(); err != nil {
+            return ctx.Error(err)
+        }
+        return ctx.Raw(title)
+    }).
+        Post("avatar", func(ctx *HttpContext) ActionResulter {
+        avatar := form.NewFileField("file", "File", true)
+        if err := ctx.FillForm(form.NewForm(avatar)); err != nil {
+            return ctx.Error(err)
+        }
+        return ctx.Raw(avatar.File().Filename)
+    }).
+        Post("small", func(ctx *HttpContext) ActionResulter {
+        return ctx.Raw(ctx.Get("title"))
+    }).MaxBodyBytes(10)
+
+    rh := createTestHandler(&ServerConfig{MaxBodyBytes: 1 << 10})
+
+    w := httptest.NewRecorder()
+    req := createMultipartRequest("/uploadtest/save", map[string]string{"title": "hi"}, "file", "a.txt", "hello goku")
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusOK)
+    assert.Equals(t, w.Body.String(), "hi:a.txt")
+    saved, _ := ioutil.ReadFile(path.Join(dir, "a.txt"))
+    assert.Equals(t, string(saved), "hello goku")
+
+    // larger than ServerConfig.MaxBodyBytes
+    w = httptest.NewRecorder()
+    req = createMultipartRequest("/uploadtest/save", nil, "file", "b.txt", strings.Repeat("a", 2<<10))
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusRequestEntityTooLarge)
+
+    // a streamed body larger than ServerConfig.MaxBodyBytes, found by FormFile
+    w = httptest.NewRecorder()
+    req = createMultipartRequest("/uploadtest/save", nil, "file", "b.txt", strings.Repeat("a", 2<<10))
+    req.ContentLength = -1
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusRequestEntityTooLarge)
+    _, err := os.Stat(path.Join(dir, "b.txt"))
+    assert.True(t, os.IsNotExist(err))
+
+    // found by Get & FormError
+    w = httptest.NewRecorder()
+    req = createMultipartRequest("/uploadtest/title", map[string]string{"title": "hi"}, "file", "b.txt", strings.Repeat("a", 2<<10))
+    req.ContentLength = -1
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusRequestEntityTooLarge)
+    w = httptest.NewRecorder()
+    req = createMultipartRequest("/uploadtest/title", map[string]string{"title": "hi"}, "file", "c.txt", "c")
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Body.String(), "hi")
+
+    // the form filled by the parsed multipart form
+    w = httptest.NewRecorder()
+    rh.ServeHTTP(w, createMultipartRequest("/uploadtest/avatar", nil, "file", "d.txt", "d"))
+    assert.Equals(t, w.Body.String(), "d.txt")
+
+    // larger than the action's MaxBodyBytes
+    w = httptest.NewRecorder()
+    req, _ = http.NewRequest("POST", "/uploadtest/small", strings.NewReader("title=hello+goku"))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.ContentLength = -1
+    rh.ServeHTTP(w, req)
+    assert.Equals(t, w.Code, http.StatusRequestEntityTooLarge)
+}
+
+func TestFormErrorLoggedOnce(t *testing.T) {
+    out := new(bytes.Buffer)
+    old := GetStructLogger()
+    SetStructLogger(CreateStructLogger(out, LOG_LEVEL_LOG, &LogfmtEncoder{}))
+    defer SetStructLogger(old)
+
+    ctx, _ := createTestContext("POST", "/uploadtest/title", map[string]string{
+        "Content-Type": "multipart/form-data; boundary=nosuch",
+    })
+    ctx.Request.Body = ioutil.NopCloser(strings.NewReader("broken"))
+    ctx.RouteData = &RouteData{Route: &Route{Name: "default"}}
+    for i := 0; i < 3; i++ {
+        assert.Equals(t, ctx.Get("title"), "")
+    }
+    assert.True(t, ctx.FormError() != nil)
+    assert.Equals(t, strings.Count(out.String(), "parse multipart form error"), 1)
+}
+
+func TestUploadTempFilesRemoved(t *testing.T) {
+    tmp, _ := ioutil.TempDir("", "goku-uploadtmp")
+    defer os.RemoveAll(tmp)
+    oldTmp := os.Getenv("TMPDIR")
+    os.Setenv("TMPDIR", tmp)
+    defer os.Setenv("TMPDIR", oldTmp)
+
+    Controller("uploadtmptest").
+        Post("save", func(ctx *HttpContext) ActionResulter {
+        f, err := ctx.FormFile("file")
+        if err != nil {
+            return ctx.Error(err)
+        }
+        return ctx.Raw(f.Filename)
+    })
+    rh := createTestHandler(&ServerConfig{MultipartMemory: 10})
+
+    w := httptest.NewRecorder()
+    rh.ServeHTTP(w, createMultipartRequest("/uploadtmptest/save", nil, "file", "big.txt", strings.Repeat("a", 5000)))
+    assert.Equals(t, w.Body.String(), "big.txt")
+    // the file part is stored in a temp file, removed after the request
+    files, _ := ioutil.ReadDir(tmp)
+    assert.Equals(t, len(files), 0)
+}
+
+func TestUploadTooLargeCloseConnection(t *testing.T) {
+    Controller("uploadclosetest").
+        Post("save", func(ctx *HttpContext) ActionResulter {
+        f, err := ctx.FormFile("file")
+        if err != nil {
+            return ctx.Error(err)
+        }
+        return ctx.Raw(f.Filename)
+    })
+    ts := httptest.NewServer(createTestHandler(&ServerConfig{MaxBodyBytes: 1 << 10}))
+    defer ts.Close()
+
+    // the streamed body, no Content-Length
+    req := createMultipartRequest(ts.URL+"/uploadclosetest/save", nil, "file", "b.txt", strings.Repeat("a", 2<<10))
+    req.Body = ioutil.NopCloser(req.Body)
+    req.ContentLength = -1
+    resp, err := http.DefaultClient.Do(req)
+    assert.Equals(t, err, nil)
+    resp.Body.Close()
+    assert.Equals(t, resp.StatusCode, http.StatusRequestEntityTooLarge)
+    // the rest of the body is not read, so not keep-alive
+    assert.True(t, resp.Close)
+}
@@ -1,36 +1,98 @@
 package goku
 
 import (
+    "bytes"
+    "errors"
     "fmt"
     "github.com/QLeelulu/goku/utils"
     "html/template"
     "io"
+    "io/ioutil"
+    "os"
     "path"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
     "strings"
+    "sync"
 )
 
 type ViewData struct {
     Data    map[string]interface{}
     Model   interface{}
     Globals map[string]interface{}
-    Body    interface{} // if in layout template, this will set
+    Body    interface{} // the rendered view or child layout, in the layout template: {{.Body}}
+
+    renderer *viewRenderer // for the partial func
+
+    partialDepth int // the depth of the nested partials, see MAX_PARTIAL_DEPTH
 }
 
 // TemplateEnginer interface
 type TemplateEnginer interface {
-    // render the view with viewData and write to w
-    Render(viewpath string, layoutPath string, viewData *ViewData, w io.Writer)
+    // render the view with viewData and write to w,
+    // nothing is written to w if returns an error
+    Render(viewpath string, layoutPath string, viewData *ViewData, w io.Writer) error
     // return whether the tempalte support layout
     SupportLayout() bool
     // template file ext name, default is ".html"
     Ext() string
 }
 
-// DefaultTemplateEngine
+// LegacyTemplateEnginer is the TemplateEnginer panics on the errors,
+// wrap it by WrapTemplateEnginer
+type LegacyTemplateEnginer interface {
+    Render(viewpath string, layoutPath string, viewData *ViewData, w io.Writer)
+    SupportLayout() bool
+    Ext() string
+}
+
+// WrapTemplateEnginer wraps the LegacyTemplateEnginer to a TemplateEnginer,
+// the panic of Render is returned as the error:
+//      config.TemplateEnginer = goku.WrapTemplateEnginer(myTemplateEngine)
+// the output is buffered, so nothing is written if it panics
+func WrapTemplateEnginer(te LegacyTemplateEnginer) TemplateEnginer {
+    return &legacyTemplateEngine{te}
+}
+
+type legacyTemplateEngine struct {
+    LegacyTemplateEnginer
+}
+
+func (te *legacyTemplateEngine) Render(viewpath string, layoutPath string, viewData *ViewData, w io.Writer) error {
+    var b bytes.Buffer
+    if err := catchPanic(func() {
+        te.LegacyTemplateEnginer.Render(viewpath, layoutPath, viewData, &b)
+    }); err != nil {
+        return err
+    }
+    _, err := b.WriteTo(w)
+    return err
+}
+
+// DefaultTemplateEngine.
+// it is safe for concurrent use, TemplateCache must not be
+// changed directly after the engine is used
 type DefaultTemplateEngine struct {
     ExtName       string
     UseCache      bool
     TemplateCache map[string]*template.Template
+    // the funcs can be used in the templates, with the builtin funcs.
+    // add the funcs by AddFunc after the engine is used
+    Funcs template.FuncMap
+
+    mu       sync.RWMutex // for TemplateCache, Funcs & parsing
+    parsing  map[string]*templateParsing
+    cacheGen int // changed by Invalidate
+}
+
+// the parsing templates, the goroutines render the same
+// templates at the same time wait for the same parsing
+type templateParsing struct {
+    done chan struct{}
+    tmpl *template.Template
+    err  error
 }
 
 // template file ext name, default is ".html"
@@ -46,35 +108,223 @@ func (te *DefaultTemplateEngine) SupportLayout() bool {
     return true
 }
 
-func (te *DefaultTemplateEngine) Render(filepath string, layoutPath string, viewData *ViewData, wr io.Writer) {
+// AddFunc adds a func can be used in the templates,
+// override the builtin func with the same name.
+// the cached templates are parsed again with the func
+func (te *DefaultTemplateEngine) AddFunc(name string, fn interface{}) {
+    te.AddFuncs(template.FuncMap{name: fn})
+}
+
+// AddFuncs adds the funcs, see AddFunc
+func (te *DefaultTemplateEngine) AddFuncs(funcs template.FuncMap) {
+    te.mu.Lock()
+    defer te.mu.Unlock()
+    // copy on write, the parsing goroutines may be reading it
+    m := make(template.FuncMap, len(te.Funcs)+len(funcs))
+    for k, v := range te.Funcs {
+        m[k] = v
+    }
+    for k, v := range funcs {
+        m[k] = v
+    }
+    te.Funcs = m
+    te.cacheGen++
+    te.TemplateCache = make(map[string]*template.Template)
+}
+
+func (te *DefaultTemplateEngine) funcs() template.FuncMap {
+    te.mu.RLock()
+    defer te.mu.RUnlock()
+    return te.Funcs
+}
+
+func (te *DefaultTemplateEngine) Render(filepath string, layoutPath string, viewData *ViewData, wr io.Writer) error {
     if te.SupportLayout() && layoutPath != "" {
-        te.render([]string{layoutPath, filepath}, viewData, wr)
-    } else {
-        te.render([]string{filepath}, viewData, wr)
+        return te.RenderLayouts(filepath, []string{layoutPath}, viewData, wr)
     }
+    return te.RenderLayouts(filepath, nil, viewData, wr)
 }
 
-func (te *DefaultTemplateEngine) render(filepaths []string, viewData interface{}, wr io.Writer) {
-    var tmpl *template.Template
-    var err error
-    cacheKey := strings.Join(filepaths, "_")
-    if te.UseCache {
-        tmpl = te.TemplateCache[cacheKey]
+// get the parsed templates from the cache, or parse them.
+// the templates are parsed only once for the concurrent calls
+func (te *DefaultTemplateEngine) parse(filepaths []string) (*template.Template, error) {
+    if !te.UseCache {
+        return te.parseFiles(filepaths)
     }
-    if tmpl == nil {
-        tmpl, err = template.ParseFiles(filepaths...)
-        if err != nil {
-            panic("DefaultTemplateEngine.Render: parse template \"" + strings.Join(filepaths, ", ") + "\" error, " + err.Error())
+    cacheKey := templateCacheKey(filepaths)
+    te.mu.RLock()
+    tmpl := te.TemplateCache[cacheKey]
+    te.mu.RUnlock()
+    if tmpl != nil {
+        return tmpl, nil
+    }
+
+    te.mu.Lock()
+    if tmpl = te.TemplateCache[cacheKey]; tmpl != nil {
+        te.mu.Unlock()
+        return tmpl, nil
+    }
+    if p, ok := te.parsing[cacheKey]; ok {
+        te.mu.Unlock()
+        <-p.done
+        return p.tmpl, p.err
+    }
+    if te.parsing == nil {
+        te.parsing = make(map[string]*templateParsing)
+    }
+    p := &templateParsing{done: make(chan struct{})}
+    te.parsing[cacheKey] = p
+    gen := te.cacheGen
+    te.mu.Unlock()
+
+    p.tmpl, p.err = te.parseFiles(filepaths)
+
+    te.mu.Lock()
+    delete(te.parsing, cacheKey)
+    // not cache it if the files changed while parsing
+    if p.err == nil && gen == te.cacheGen {
+        te.TemplateCache[cacheKey] = p.tmpl
+    }
+    te.mu.Unlock()
+    close(p.done)
+    return p.tmpl, p.err
+}
+
+// parse the files into a template set, the templates are named by the full paths,
+// so the layouts with the same file name in different dirs can be nested
+func (te *DefaultTemplateEngine) parseFiles(filepaths []string) (*template.Template, error) {
+    tmpl := template.New(filepaths[0]).
+        Funcs(builtinTemplateFuncs).
+        Funcs(te.funcs())
+    for _, file := range filepaths {
+        b, err := ioutil.ReadFile(file)
+        if err == nil {
+            t := tmpl
+            if file != filepaths[0] {
+                t = tmpl.New(file)
+            }
+            _, err = t.Parse(string(b))
         }
-        if te.UseCache {
-            te.TemplateCache[cacheKey] = tmpl
+        if err != nil {
+            return nil, &TemplateError{Files: filepaths, Parse: true, Err: err}
         }
     }
+    return tmpl, nil
+}
 
-    err = tmpl.Execute(wr, viewData)
+// Precompile parses all the views under the RootDir of ve,
+// with their layouts, to find the template errors at startup.
+// the parsed templates are cached if UseCache.
+// returns all the errors joined
+func (te *DefaultTemplateEngine) Precompile(ve *DefaultViewEngine) error {
+    var errs []error
+    err := filepath.Walk(ve.RootDir, func(p string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if info.IsDir() || path.Ext(p) != ve.ExtName {
+            return nil
+        }
+        files := []string{p}
+        rel, _ := filepath.Rel(ve.RootDir, p)
+        // the views of the controllers, e.g. home/index.html, with the layouts
+        if dir := path.Dir(filepath.ToSlash(rel)); dir != "." && dir != "shared" && !ve.isLayout(p) {
+            vi := &ViewInfo{Controller: dir, View: "/" + filepath.ToSlash(rel)}
+            vi.View = strings.TrimSuffix(vi.View, ve.ExtName)
+            viewPath, layoutPaths, err := ve.FindLayouts(vi)
+            if err != nil {
+                errs = append(errs, err)
+                return nil
+            }
+            files = templateFiles(viewPath, layoutPaths)
+        }
+        if _, err := te.parse(files); err != nil {
+            errs = append(errs, err)
+        }
+        return nil
+    })
     if err != nil {
-        panic(err)
+        errs = append(errs, err)
+    }
+    return errors.Join(errs...)
+}
+
+// call the func, returns the panic as an error,
+// for the engines still panic, see WrapViewEnginer
+func catchPanic(fn func()) (err error) {
+    defer func() {
+        if e := recover(); e != nil {
+            if er, ok := e.(error); ok {
+                err = er
+            } else {
+                err = fmt.Errorf("%v", e)
+            }
+        }
+    }()
+    fn()
+    return nil
+}
+
+// TemplateError is the error of parsing or executing the template files
+type TemplateError struct {
+    Files []string
+    Parse bool // parse error, or execute error if false
+    Err   error
+}
+
+func (e *TemplateError) Error() string {
+    if e.Parse {
+        return "parse template \"" + strings.Join(e.Files, ", ") + "\" error, " + e.Err.Error()
     }
+    return e.Err.Error()
+}
+
+func (e *TemplateError) Unwrap() error {
+    return e.Err
+}
+
+// matches "template: index.html:3:" or "template: index.html:3:12:"
+var regTemplateErrorLocation = regexp.MustCompile(`template: ([^:\s]+):(\d+):`)
+
+// Location gets the file and line of the error, "" & 0 if unknown
+func (e *TemplateError) Location() (file string, line int) {
+    m := regTemplateErrorLocation.FindStringSubmatch(e.Err.Error())
+    if m == nil {
+        return "", 0
+    }
+    line, _ = strconv.Atoi(m[2])
+    // the template name is the path or the base name of the file
+    for _, f := range e.Files {
+        if f == m[1] || path.Base(f) == m[1] {
+            return f, line
+        }
+    }
+    return m[1], line
+}
+
+// Invalidate drops the parsed templates use the file,
+// they will be parsed again at the next render
+func (te *DefaultTemplateEngine) Invalidate(file string) {
+    te.mu.Lock()
+    defer te.mu.Unlock()
+    te.cacheGen++
+    for key := range te.TemplateCache {
+        if templateCacheKeyHas(key, file) {
+            delete(te.TemplateCache, key)
+        }
+    }
+}
+
+// CachedTemplates gets the cache keys of the parsed templates
+func (te *DefaultTemplateEngine) CachedTemplates() []string {
+    te.mu.RLock()
+    defer te.mu.RUnlock()
+    keys := make([]string, 0, len(te.TemplateCache))
+    for k := range te.TemplateCache {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
 }
 
 type ViewInfo struct {
@@ -86,11 +336,38 @@ type ViewInfo struct {
 // For how to find the view file.
 type ViewEnginer interface {
     // find the view and layout
-    // if template engine not suppot layout, just return empty string
+    // if template engine not suppot layout, just return empty string.
+    // returns an error if the view not found
+    FindView(vi *ViewInfo) (viewPath string, layoutPath string, err error)
+}
+
+// LegacyViewEnginer is the ViewEnginer panics if the view not found,
+// wrap it by WrapViewEnginer
+type LegacyViewEnginer interface {
     FindView(vi *ViewInfo) (viewPath string, layoutPath string)
 }
 
-// DefaultViewEngine
+// WrapViewEnginer wraps the LegacyViewEnginer to a ViewEnginer,
+// the panic of FindView is returned as the error:
+//      config.ViewEnginer = goku.WrapViewEnginer(myViewEngine)
+func WrapViewEnginer(ve LegacyViewEnginer) ViewEnginer {
+    return &legacyViewEngine{ve}
+}
+
+type legacyViewEngine struct {
+    ve LegacyViewEnginer
+}
+
+func (ve *legacyViewEngine) FindView(vi *ViewInfo) (viewPath string, layoutPath string, err error) {
+    err = catchPanic(func() {
+        viewPath, layoutPath = ve.ve.FindView(vi)
+    })
+    return
+}
+
+// DefaultViewEngine.
+// it is safe for concurrent use, Caches must not be
+// changed directly after the engine is used
 type DefaultViewEngine struct {
     ExtName               string // template file ext name, default is ".html"
     RootDir               string // view's root dir, must set
@@ -99,17 +376,56 @@ type DefaultViewEngine struct {
     LayoutLocationFormats []string
     UseCache              bool              // whether cache the viewfile
     Caches                map[string]string // controller & action & view to the real-file-path cache
+    // the other template file ext names to look up, after ExtName,
+    // e.g. ".txt" for the TextTemplateEngine. see MultiTemplateEngine
+    ExtNames []string
+
+    mu         sync.RWMutex // for Caches & directives
+    directives map[string]layoutDirective
 }
 
-func (ve *DefaultViewEngine) FindView(vi *ViewInfo) (viewPath string, layoutPath string) {
-    viewPath = ve.lookup(vi, false)
+// FindView finds the view and its layout.
+// the view can set its layout by the layout directive at the beginning,
+// used if the layout is not set by the action:
+//      {{/* layout: admin */}}
+// or no layout:
+//      {{/* layout: none */}}
+func (ve *DefaultViewEngine) FindView(vi *ViewInfo) (viewPath string, layoutPath string, err error) {
+    viewPath, err = ve.lookup(vi, false, "")
+    if err != nil {
+        return "", "", err
+    }
     if !vi.IsPartial {
-        layoutPath = ve.lookup(vi, true)
+        if vi.Layout == "" {
+            if layout, ok := ve.layoutDirective(viewPath); ok {
+                if layout == "none" {
+                    return
+                }
+                lvi := *vi
+                lvi.Layout = layout
+                vi = &lvi
+            }
+        }
+        // the layout has the same ext as the view
+        layoutPath, err = ve.lookup(vi, true, path.Ext(viewPath))
     }
     return
 }
 
-func (ve *DefaultViewEngine) lookup(vi *ViewInfo, isLayout bool) string {
+// whether the file is a layout, by the name
+func (ve *DefaultViewEngine) isLayout(file string) bool {
+    return strings.TrimSuffix(path.Base(file), path.Ext(file)) == ve.Layout
+}
+
+// all the ext names to look up
+func (ve *DefaultViewEngine) exts() []string {
+    return append([]string{ve.ExtName}, ve.ExtNames...)
+}
+
+// look up the view or the layout, ext is the ext of the layout,
+// the view is looked up with all the ext names.
+// returns an error if the view not found, "" if the layout not found
+func (ve *DefaultViewEngine) lookup(vi *ViewInfo, isLayout bool, ext string) (string, error) {
     var viewName, cacheKey string
     var locas []string
     if !vi.IsPartial && isLayout {
@@ -120,51 +436,108 @@ func (ve *DefaultViewEngine) lookup(vi *ViewInfo, isLayout bool) string {
             viewName = vi.Layout
         }
         if viewName == "" {
-            return ""
+            return "", nil
         }
-        cacheKey = vi.Controller + "_layout_" + viewName
+        cacheKey = vi.Controller + "_layout_" + viewName + ext
         locas = ve.LayoutLocationFormats
     } else {
         viewName = vi.View
         if viewName == "" {
             viewName = vi.Action
         }
+        if viewName == "" {
+            return "", fmt.Errorf("DefaultViewEngine: the view name is empty, {controller: %s}", vi.Controller)
+        }
         cacheKey = vi.Controller + "_" + viewName
         locas = ve.ViewLocationFormats
     }
-    viewName = viewName + ve.ExtName
+    exts := ve.exts()
+    if isLayout {
+        exts = []string{ext}
+    }
+    // the view name with the ext, e.g. "welcome.txt"
+    if e := path.Ext(viewName); e != "" && !isLayout {
+        for _, x := range exts {
+            if x == e {
+                exts = []string{""}
+                break
+            }
+        }
+    }
     if ve.UseCache {
-        if v, ok := ve.Caches[cacheKey]; ok {
-            return v
+        ve.mu.RLock()
+        v, ok := ve.Caches[cacheKey]
+        ve.mu.RUnlock()
+        if ok {
+            return v, nil
         }
     }
     lookPaths := make([]string, 0, 3)
     // Absolute path, 
     // direct use viewpath
     if viewName[0] == '/' {
-        viewPath := path.Join(ve.RootDir, viewName)
-        if ok, _ := utils.FileExists(viewPath); ok {
-            ve.Caches[cacheKey] = viewPath
-            return viewPath
-        }
-        lookPaths = append(lookPaths, viewPath)
-    } else {
-        for _, format := range locas {
+        locas = []string{"{0}"}
+    }
+    // the controller's view of any ext first, then the shared view
+    for _, format := range locas {
+        for _, x := range exts {
             viewPath := strings.Replace(format, "{1}", vi.Controller, 1)
-            viewPath = strings.Replace(viewPath, "{0}", viewName, 1)
+            viewPath = strings.Replace(viewPath, "{0}", viewName+x, 1)
             viewPath = path.Join(ve.RootDir, viewPath)
             if ok, _ := utils.FileExists(viewPath); ok {
-                ve.Caches[cacheKey] = viewPath
-                return viewPath
+                ve.setCache(cacheKey, viewPath)
+                return viewPath, nil
             }
             lookPaths = append(lookPaths, viewPath)
         }
     }
     if !isLayout {
-        panic(fmt.Sprintf("DefaultViewEngine: can't find the view for {controller: %s, action: %s, view: %s}, look up paths: %s",
-            vi.Controller, vi.Action, vi.View, lookPaths))
+        return "", fmt.Errorf("DefaultViewEngine: can't find the view for {controller: %s, action: %s, view: %s}, look up paths: %s",
+            vi.Controller, vi.Action, vi.View, lookPaths)
+    }
+    return "", nil
+}
+
+func (ve *DefaultViewEngine) setCache(cacheKey, viewPath string) {
+    if !ve.UseCache {
+        return
+    }
+    ve.mu.Lock()
+    ve.Caches[cacheKey] = viewPath
+    ve.mu.Unlock()
+}
+
+// Invalidate drops the cached lookups found the file,
+// or all the lookups if none found it, the file may be a new view
+// which overrides the shared one
+func (ve *DefaultViewEngine) Invalidate(file string) {
+    if !strings.HasPrefix(file, ve.RootDir) {
+        return
+    }
+    ve.mu.Lock()
+    defer ve.mu.Unlock()
+    delete(ve.directives, file)
+    found := false
+    for key, viewPath := range ve.Caches {
+        if viewPath == file {
+            delete(ve.Caches, key)
+            found = true
+        }
+    }
+    if !found {
+        ve.Caches = make(map[string]string)
     }
-    return ""
+}
+
+// CachedViews gets a copy of the view file cache
+func (ve *DefaultViewEngine) CachedViews() map[string]string {
+    ve.mu.RLock()
+    defer ve.mu.RUnlock()
+    m := make(map[string]string, len(ve.Caches))
+    for k, v := range ve.Caches {
+        m[k] = v
+    }
+    return m
 }
 
 // create a default ViewEnginer.
@@ -212,11 +585,30 @@ func CreateDefaultTemplateEngine(useCache bool) *DefaultTemplateEngine {
     return te
 }
 
-var globalViewData map[string]interface{} = make(map[string]interface{})
+// the global view data, copy on write,
+// so the views can read the map without the lock
+var globalViewData struct {
+    sync.RWMutex
+    data map[string]interface{}
+}
 
 // SetGlobalViewData adds a view data to the global,
 // that all the view can use it
 // by {{.Global.key}}
 func SetGlobalViewData(key string, val interface{}) {
-    globalViewData[key] = val
+    globalViewData.Lock()
+    defer globalViewData.Unlock()
+    m := make(map[string]interface{}, len(globalViewData.data)+1)
+    for k, v := range globalViewData.data {
+        m[k] = v
+    }
+    m[key] = val
+    globalViewData.data = m
+}
+
+// the global view data for ViewData.Globals, must not be changed
+func getGlobalViewData() map[string]interface{} {
+    globalViewData.RLock()
+    defer globalViewData.RUnlock()
+    return globalViewData.data
 }
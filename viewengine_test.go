@@ -0,0 +1,192 @@
+package goku
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path"
+    "strings"
+    "sync"
+    "testing"
+    "github.com/couchbaselabs/go.assert"
+)
+
+func createTestViews(t *testing.T, files map[string]string) string {
+    dir, err := ioutil.TempDir("", "goku-views")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for name, content := range files {
+        os.MkdirAll(path.Dir(path.Join(dir, name)), 0755)
+        ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644)
+    }
+    return dir
+}
+
+func TestTemplateCacheConcurrent(t *testing.T) {
+    files := map[string]string{
+        "shared/layout.html": `<body>{{template "body" .}}</body>`,
+    }
+    for i := 0; i < 5; i++ {
+        files[fmt.Sprintf("home/v%d.html", i)] = fmt.Sprintf(`{{define "body"}}v%d {{.Model}}{{end}}`, i)
+    }
+    dir := createTestViews(t, files)
+    defer os.RemoveAll(dir)
+
+    te := CreateDefaultTemplateEngine(true)
+    ve := CreateDefaultViewEngine(dir, "", "", true)
+    var wg sync.WaitGroup
+    for n := 0; n < 50; n++ {
+        wg.Add(1)
+        go func(n int) {
+            defer wg.Done()
+            view := fmt.Sprintf("v%d", n%5)
+            viewPath, layoutPath, err := ve.FindView(&ViewInfo{Controller: "home", View: view})
+            if err != nil {
+                t.Error(err)
+                return
+            }
+            var b bytes.Buffer
+            if err = te.Render(viewPath, layoutPath, &ViewData{Model: n}, &b); err != nil {
+                t.Error(err)
+            }
+            if b.String() != fmt.Sprintf("<body>%s %d</body>", view, n) {
+                t.Error("wrong output:", b.String())
+            }
+            if n%10 == 0 {
+                te.Invalidate(viewPath)
+                ve.Invalidate(viewPath)
+            }
+        }(n)
+    }
+    wg.Wait()
+    assert.True(t, len(te.CachedTemplates()) <= 5)
+    for _, key := range te.CachedTemplates() {
+        assert.True(t, strings.HasPrefix(key, path.Join(dir, "shared/layout.html")+"_"))
+    }
+}
+
+func TestViewEngineWithoutCache(t *testing.T) {
+    dir := createTestViews(t, map[string]string{"home/index.html": "index"})
+    defer os.RemoveAll(dir)
+
+    ve := CreateDefaultViewEngine(dir, "", "", false)
+    viewPath, layoutPath, err := ve.FindView(&ViewInfo{Controller: "home", Action: "index"})
+    assert.Equals(t, err, nil)
+    assert.Equals(t, viewPath, path.Join(dir, "home/index.html"))
+    assert.Equals(t, layoutPath, "")
+    assert.Equals(t, len(ve.CachedViews()), 0)
+}
+
+func TestPrecompile(t *testing.T) {
+    dir := createTestViews(t, map[string]string{
+        "shared/layout.html": `<body>{{template "body" .}}</body>`,
+        "shared/_nav.html":   `<nav></nav>`,
+        "home/index.html":    `{{define "body"}}index{{end}}`,
+        "home/about.html":    `{{define "body"}}about{{end}}`,
+    })
+    defer os.RemoveAll(dir)
+
+    te := CreateDefaultTemplateEngine(true)
+    ve := CreateDefaultViewEngine(dir, "", "", true)
+    assert.Equals(t, te.Precompile(ve), nil)
+    layout := path.Join(dir, "shared/layout.html")
+    assert.Equals(t, strings.Join(te.CachedTemplates(), ","), strings.Join([]string{
+        path.Join(dir, "shared/_nav.html"),
+        layout,
+        layout + "_" + path.Join(dir, "home/about.html"),
+        layout + "_" + path.Join(dir, "home/index.html"),
+    }, ","))
+
+    ioutil.WriteFile(path.Join(dir, "home/broken.html"), []byte("ok\n{{if}}"), 0644)
+    err := te.Precompile(ve)
+    var tErr *TemplateError
+    assert.True(t, errors.As(err, &tErr))
+    file, line := tErr.Location()
+    assert.Equals(t, file, path.Join(dir, "home/broken.html"))
+    assert.Equals(t, line, 2)
+}
+
+type panicViewEngine struct{}
+
+func (ve panicViewEngine) FindView(vi *ViewInfo) (string, string) {
+    panic("no view " + vi.View)
+}
+
+type panicTemplateEngine struct{}
+
+func (te panicTemplateEngine) Render(viewpath string, layoutPath string, viewData *ViewData, w io.Writer) {
+    w.Write([]byte("partial"))
+    panic("render " + viewpath)
+}
+func (te panicTemplateEngine) SupportLayout() bool { return false }
+func (te panicTemplateEngine) Ext() string         { return ".html" }
+
+func TestRenderErrors(t *testing.T) {
+    dir := createTestViews(t, map[string]string{
+        "shared/layout.html":  `<body>{{.Body}}</body>`,
+        "shared/error.html":   `{{.Model.Nope}}`,
+        "rendertest/exec.html": "<p>before</p>\n{{.Model.Name.Missing}}<p>after</p>",
+    })
+    defer os.RemoveAll(dir)
+    te := CreateDefaultTemplateEngine(true)
+    ve := CreateDefaultViewEngine(dir, "", "", true)
+
+    // nothing written if failed
+    viewPath, layoutPath, err := ve.FindView(&ViewInfo{Controller: "rendertest", View: "exec"})
+    assert.Equals(t, err, nil)
+    var b bytes.Buffer
+    err = te.Render(viewPath, layoutPath, &ViewData{Model: map[string]string{"Name": "goku"}}, &b)
+    assert.Equals(t, b.Len(), 0)
+    tErr, ok := err.(*TemplateError)
+    assert.True(t, ok)
+    assert.True(t, !tErr.Parse)
+    f, line := tErr.Location()
+    assert.Equals(t, f, viewPath)
+    assert.Equals(t, line, 2)
+
+    _, _, err = ve.FindView(&ViewInfo{Controller: "rendertest", View: "nosuch"})
+    assert.True(t, err != nil)
+    assert.True(t, strings.Contains(err.Error(), "nosuch"))
+
+    Controller("rendertest").
+        Get("exec", func(ctx *HttpContext) ActionResulter {
+        return ctx.View(map[string]string{"Name": "goku"})
+    })
+    get := func(sc *ServerConfig, url string) (int, string) {
+        rh := createTestHandler(sc)
+        rh.TemplateEnginer, rh.ViewEnginer = te, ve
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", url, nil)
+        req.Header.Set("Accept", "text/html")
+        rh.ServeHTTP(w, req)
+        return w.Code, w.Body.String()
+    }
+
+    // the broken error view is not used to render its own error
+    code, body := get(&ServerConfig{
+        ErrorHandlers: CreateErrorHandlers().Status(0, ViewErrorHandler("error")),
+    }, "/rendertest/exec")
+    assert.Equals(t, code, http.StatusInternalServerError)
+    assert.Equals(t, body, "Internal Server Error")
+
+    // the dev error page with the template location
+    code, body = get(&ServerConfig{Debug: true}, "/rendertest/exec")
+    assert.Equals(t, code, http.StatusInternalServerError)
+    assert.True(t, strings.Contains(body, viewPath))
+    assert.True(t, strings.Contains(body, "can&#39;t evaluate field Missing"))
+    assert.True(t, !strings.Contains(body, "<p>before</p>"))
+
+    // the legacy engines panic
+    _, _, err = WrapViewEnginer(panicViewEngine{}).FindView(&ViewInfo{View: "index"})
+    assert.Equals(t, err.Error(), "no view index")
+    b.Reset()
+    err = WrapTemplateEnginer(panicTemplateEngine{}).Render("index.html", "", &ViewData{}, &b)
+    assert.Equals(t, err.Error(), "render index.html")
+    assert.Equals(t, b.Len(), 0)
+}